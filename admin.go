@@ -0,0 +1,205 @@
+package main
+
+import (
+	"agent/controller"
+	"agent/logging"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// crawlBuckets 爬取和排队耗时的分桶，单位秒
+var crawlBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60}
+
+var (
+	tasksFetched = promauto.NewCounterVec(prometheus.CounterOpts{Name: "ecsagent_tasks_fetched_total",
+		Help: "从主控领取的任务数"}, []string{"flag"})
+	tasksSucceeded = promauto.NewCounterVec(prometheus.CounterOpts{Name: "ecsagent_tasks_succeeded_total",
+		Help: "爬取成功的任务数"}, []string{"flag"})
+	tasksFailed = promauto.NewCounterVec(prometheus.CounterOpts{Name: "ecsagent_tasks_failed_total",
+		Help: "失败的任务数，class 为爬取错误分类，或 invalid_task、signature、expired"}, []string{"flag", "class"})
+	crawlDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{Name: "ecsagent_crawl_duration_seconds",
+		Help: "单个任务的爬取耗时，包含重试", Buckets: crawlBuckets}, []string{"flag"})
+	queueEmptyPolls = promauto.NewCounterVec(prometheus.CounterOpts{Name: "ecsagent_queue_empty_polls_total",
+		Help: "拉取任务时队列为空的次数"}, []string{"flag"})
+	queueWait = promauto.NewHistogram(prometheus.HistogramOpts{Name: "ecsagent_task_queue_wait_seconds",
+		Help: "任务在本地队列中等待执行的时间", Buckets: crawlBuckets})
+)
+
+// 任务失败但不是爬取错误时的分类
+const (
+	failureInvalidTask = "invalid_task" // Token、URL 或 Tag 无效
+	failureSignature   = "signature"    // 任务签名校验失败
+	failureExpired     = "expired"      // 在本地队列中超过截止时间或 TTL
+	failureUnknown     = "unknown"
+)
+
+// registerClientMetrics 注册输出时从客户端读取的指标
+func registerClientMetrics(c *SpiderClient) {
+	gauge := func(name, help string, labels prometheus.Labels, fn func() float64) {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help, ConstLabels: labels}, fn)
+	}
+	gauge("ecsagent_tasks_inflight", "正在执行的任务数", nil, func() float64 {
+		return float64(c.limiter.InUse())
+	})
+	gauge("ecsagent_task_queue_depth", "本地队列中等待执行的任务数", nil, func() float64 {
+		return float64(c.queue.Len())
+	})
+	gauge("ecsagent_paused", "主控暂停爬虫、停止拉取新任务时为 1", nil, func() float64 {
+		return boolValue(c.IsPaused())
+	})
+	gauge("ecsagent_tasks_max_concurrent", "同时执行任务数的上限", nil, func() float64 {
+		return float64(c.limiter.Limit())
+	})
+	for _, mode := range []string{modeGRPC, modeAPI} {
+		gauge("ecsagent_transport_mode", "当前通信模式，使用中的为 1", prometheus.Labels{"mode": mode}, func() float64 {
+			return boolValue(modeOf(c.transportState()) == mode)
+		})
+	}
+	for _, state := range []controller.TransportState{
+		controller.StateGRPC, controller.StateDegraded, controller.StateAPI, controller.StateRecovering,
+	} {
+		gauge("ecsagent_transport_state", "当前通信状态，所处的状态为 1", prometheus.Labels{"state": string(state)}, func() float64 {
+			return boolValue(c.transportState() == state)
+		})
+	}
+	gauge("ecsagent_spool_depth", "本地缓存中等待重放的结果数", nil, func() float64 {
+		if c.spool == nil {
+			return 0
+		}
+		return float64(c.spool.Depth())
+	})
+}
+
+// modeOf 通信状态对应的通信模式
+func modeOf(state controller.TransportState) string {
+	switch state {
+	case controller.StateGRPC, controller.StateDegraded:
+		return modeGRPC
+	default:
+		return modeAPI
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// readyContactWindow 多久内与主控通信过才视为主控可达，需大于拉取任务的最大退避时间
+const readyContactWindow = 3 * time.Minute
+
+// readyDNSTimeout 就绪检查中域名解析的超时
+const readyDNSTimeout = 2 * time.Second
+
+// readyCheck 单项就绪检查的结果
+type readyCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+// readiness 就绪检查，dnsName 为空时不检查域名解析
+type readiness struct {
+	client  *SpiderClient
+	dnsName string
+	started time.Time
+}
+
+// checkController 最近是否收到过主控的响应
+func (r *readiness) checkController(now time.Time) readyCheck {
+	last := controller.LastContact()
+	if last.IsZero() {
+		return readyCheck{Detail: "尚未收到主控响应"}
+	}
+	ago := now.Sub(last).Truncate(time.Second)
+	if ago > readyContactWindow {
+		return readyCheck{Detail: fmt.Sprintf("最近一次收到主控响应在 %v 前", ago)}
+	}
+	return readyCheck{OK: true, Detail: fmt.Sprintf("%v 前收到主控响应", ago)}
+}
+
+// checkDNS 爬取使用的解析器能否解析 dnsName
+func (r *readiness) checkDNS(ctx context.Context) readyCheck {
+	if r.dnsName == "" {
+		return readyCheck{OK: true, Detail: "未配置"}
+	}
+	ctx, cancel := context.WithTimeout(ctx, readyDNSTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, r.dnsName)
+	if err != nil {
+		return readyCheck{Detail: fmt.Sprintf("解析 %s 失败: %v", r.dnsName, err)}
+	}
+	return readyCheck{OK: true, Detail: fmt.Sprintf("%s -> %s", r.dnsName, strings.Join(addrs, ","))}
+}
+
+// checkSpool 本地缓存是否已满
+func (r *readiness) checkSpool() readyCheck {
+	if r.client.spool == nil {
+		return readyCheck{OK: true, Detail: "未启用"}
+	}
+	depth := r.client.spool.Depth()
+	if r.client.spool.Full() {
+		return readyCheck{Detail: fmt.Sprintf("已满，%d 条", depth)}
+	}
+	return readyCheck{OK: true, Detail: fmt.Sprintf("%d 条", depth)}
+}
+
+// healthz 进程存活即返回 200
+func (r *readiness) healthz(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status": "ok",
+		"uptime": time.Since(r.started).Truncate(time.Second).String(),
+	})
+}
+
+// readyz 主控可达、域名解析正常且本地缓存未满时返回 200，否则返回 503
+func (r *readiness) readyz(w http.ResponseWriter, req *http.Request) {
+	checks := map[string]readyCheck{
+		"controller": r.checkController(time.Now()),
+		"dns":        r.checkDNS(req.Context()),
+		"spool":      r.checkSpool(),
+	}
+	code, status := http.StatusOK, "ok"
+	for _, check := range checks {
+		if !check.OK {
+			code, status = http.StatusServiceUnavailable, "fail"
+		}
+	}
+	// 暂停拉取是主控的指令，不影响就绪状态，只在响应中标出
+	writeJSON(w, code, map[string]any{"status": status, "checks": checks, "paused": r.client.IsPaused()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(body)
+}
+
+// startAdminServer 在 addr 上提供 /metrics、/healthz 和 /readyz，监听失败只记录日志
+func startAdminServer(addr string, client *SpiderClient, dnsName string) *http.Server {
+	r := &readiness{client: client, dnsName: dnsName, started: time.Now()}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", r.healthz)
+	mux.HandleFunc("/readyz", r.readyz)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("管理接口监听失败", logging.KeyError, err)
+		}
+	}()
+	logger.Info("管理接口已启动", "addr", addr, "paths", "/metrics, /healthz, /readyz")
+	return srv
+}
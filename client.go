@@ -3,14 +3,27 @@ package main
 import (
 	"agent/controller"
 	"agent/crawler"
+	"agent/identity"
+	"agent/limiter"
+	"agent/logging"
 	pb "agent/proto"
+	"agent/spool"
+	"agent/sysinfo"
+	"agent/tracing"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -18,88 +31,413 @@ const (
 	modeGRPC           = "grpc"
 	modeAPI            = "api"
 	maxConcurrentTasks = 10 // 限制并发任务数量
+
+	spoolReplayInterval = 30 * time.Second // 本地缓存重放检查间隔
 )
 
+// version 构建版本，编译时通过 -ldflags "-X main.version=..." 注入
+var version = "dev"
+
+var logger = logging.For("agent")
+
 type SpiderClient struct {
-	controller *controller.ControllerClient
+	controller *controllerRef
 	crawler    *crawler.Crawler
-	semaphore  chan struct{} // 用于控制并发数量
-	modeMutex  sync.RWMutex  // 保护模式切换的互斥锁
-	token      string
-	host       string
-	grpcPort   string
-	apiPort    string
-	taskFlag   string
+	limiter    *limiter.Limiter  // 控制同时执行的任务数，可在运行中调整
+	adaptive   *limiter.Adaptive // 自适应并发，固定并发时为空
+	coalescer  *coalescer        // 合并相同的爬取请求
+	queue      *taskQueue        // 已领取、等待空闲名额的任务
+	modeMutex  sync.RWMutex      // 保护模式切换的互斥锁
+	paused     atomic.Bool       // 主控通过 ControlSpiders 暂停时不再拉取新任务
+	stopWatch  context.CancelFunc
+	inflight   *inflightTasks     // 在途任务，退出时统一等待
+	spool      *spool.Spool       // 提交失败的结果缓存，为空时不缓存
+	opts       controller.Options // 重建主控客户端时使用的连接参数
+	fleetToken string             // 启动时传入的共享 Token，改用专属凭证后仍接受携带它的任务
 }
 
 // NewSpiderClient 创建新的客户端实例
 func NewSpiderClient(token, host, grpcPort, apiPort string) (*SpiderClient, error) {
-	controllerClient, err := controller.NewControllerClient(token, host, grpcPort, apiPort)
-	if err != nil {
-		return nil, err
-	}
-	newCrawler := crawler.NewCrawler()
-	return &SpiderClient{
-		controller: controllerClient,
-		crawler:    newCrawler,
-		semaphore:  make(chan struct{}, maxConcurrentTasks),
-		token:      token,
-		host:       host,
-		grpcPort:   grpcPort,
-		apiPort:    apiPort,
-		taskFlag:   "",
-	}, nil
+	return NewSpiderClientWithOptions(controller.Options{Token: token, Host: host, GrpcPort: grpcPort, ApiPort: apiPort})
 }
 
 // NewSpiderClientWithFlag 创建带任务类型的客户端实例
 func NewSpiderClientWithFlag(token, host, grpcPort, apiPort, taskFlag string) (*SpiderClient, error) {
-	controllerClientWithFlag, err := controller.NewControllerClientWithFlag(token, host, grpcPort, apiPort, taskFlag)
+	return NewSpiderClientWithOptions(controller.Options{Token: token, Host: host, GrpcPort: grpcPort, ApiPort: apiPort, TaskFlag: taskFlag})
+}
+
+// NewSpiderClientWithOptions 按主控连接参数创建客户端实例
+func NewSpiderClientWithOptions(opts controller.Options) (*SpiderClient, error) {
+	controllerClient, err := controller.New(opts)
 	if err != nil {
 		return nil, err
 	}
 	newCrawler := crawler.NewCrawler()
 	return &SpiderClient{
-		controller: controllerClientWithFlag,
+		controller: &controllerRef{ControllerClient: controllerClient},
 		crawler:    newCrawler,
-		semaphore:  make(chan struct{}, maxConcurrentTasks),
-		token:      token,
-		host:       host,
-		grpcPort:   grpcPort,
-		apiPort:    apiPort,
-		taskFlag:   taskFlag,
+		limiter:    limiter.New(maxConcurrentTasks),
+		coalescer:  newCoalescer(0),
+		queue:      newTaskQueue(maxConcurrentTasks),
+		inflight:   &inflightTasks{},
+		opts:       opts,
+		fleetToken: opts.Token,
 	}, nil
 }
 
 // SetTaskFlag 设置任务类型
 func (c *SpiderClient) SetTaskFlag(flag string) {
-	c.taskFlag = flag
+	c.modeMutex.Lock()
+	defer c.modeMutex.Unlock()
+	c.opts.TaskFlag = flag
 	c.controller.SetTaskFlag(flag)
 }
 
-// GetTask 获取任务
-func (c *SpiderClient) GetTask() (*pb.CrawlerTask, error) {
+// Register 向主控注册本机身份，主控下发专属凭证时保存并改用该凭证；
+// 注册失败时沿用上次保存的凭证。只在开始拉取任务前调用
+func (c *SpiderClient) Register(ctx context.Context, id *identity.Identity) error {
+	var credential string
+	var err error
+	if c.controller.GetMode() == modeGRPC {
+		credential, err = c.controller.RegisterGRPC(ctx)
+		if err != nil {
+			logger.Warn("gRPC 模式注册失败，尝试 API 模式", logging.KeyError, err)
+			credential, err = c.controller.RegisterAPI(ctx)
+		}
+	} else {
+		credential, err = c.controller.RegisterAPI(ctx)
+	}
+	if err != nil {
+		if id.Credential != "" {
+			logger.Warn("注册失败，沿用已保存的专属凭证", logging.KeyError, err)
+			return c.useToken(id.Credential)
+		}
+		return err
+	}
+	logger.Info("已向主控注册", "agent_id", id.AgentID)
+	if credential == "" {
+		return nil
+	}
+	if err := id.SetCredential(credential); err != nil {
+		logger.Warn("保存专属凭证失败", logging.KeyError, err)
+	}
+	return c.useToken(credential)
+}
+
+// useToken 改用新的凭证重建主控客户端
+func (c *SpiderClient) useToken(token string) error {
 	c.modeMutex.RLock()
-	mode := c.controller.GetMode()
+	opts := c.opts
 	c.modeMutex.RUnlock()
-	var task *pb.CrawlerTask
+	opts.Token = token
+	if err := c.replaceController(opts); err != nil {
+		return err
+	}
+	logger.Info("已改用专属凭证", "credential", token)
+	return nil
+}
+
+// Reconnect 用当前连接参数重建主控客户端，爬虫、并发限制和在途任务不受影响
+func (c *SpiderClient) Reconnect() error {
+	c.modeMutex.RLock()
+	opts := c.opts
+	c.modeMutex.RUnlock()
+	if err := c.replaceController(opts); err != nil {
+		return err
+	}
+	logger.Info("已重建与主控的连接")
+	return nil
+}
+
+// currentOptions 当前主控客户端的连接参数
+func (c *SpiderClient) currentOptions() controller.Options {
+	c.modeMutex.RLock()
+	defer c.modeMutex.RUnlock()
+	return c.opts
+}
+
+// UpdateControllerOptions 用新的连接参数重建主控客户端，Agent 身份沿用当前的；
+// 共享 Token 未变化时继续使用已获得的专属凭证
+func (c *SpiderClient) UpdateControllerOptions(opts controller.Options) error {
+	c.modeMutex.RLock()
+	current, fleetToken := c.opts, c.fleetToken
+	c.modeMutex.RUnlock()
+	opts.Agent = current.Agent
+	if opts.Token == fleetToken {
+		opts.Token = current.Token
+	} else {
+		fleetToken = opts.Token
+	}
+	if err := c.replaceController(opts); err != nil {
+		return err
+	}
+	c.modeMutex.Lock()
+	c.fleetToken = fleetToken
+	c.modeMutex.Unlock()
+	logger.Info("已按新配置重建与主控的连接")
+	return nil
+}
+
+// replaceController 创建新的主控客户端并替换当前的，旧客户端在在途任务全部释放后关闭
+func (c *SpiderClient) replaceController(opts controller.Options) error {
+	newController, err := controller.New(opts)
+	if err != nil {
+		return err
+	}
+	c.modeMutex.Lock()
+	old := c.controller
+	c.controller = &controllerRef{ControllerClient: newController}
+	c.opts = opts
+	c.modeMutex.Unlock()
+	old.retire()
+	return nil
+}
+
+// acquire 取得当前主控客户端的引用，用完需调用 release
+func (c *SpiderClient) acquire() *controllerRef {
+	c.modeMutex.RLock()
+	defer c.modeMutex.RUnlock()
+	c.controller.refs.Add(1)
+	return c.controller
+}
+
+// controllerRef 带引用计数的主控客户端，被替换后等最后一个使用者释放时关闭连接
+type controllerRef struct {
+	*controller.ControllerClient
+	refs    atomic.Int64
+	retired atomic.Bool
+	once    sync.Once
+}
+
+func (r *controllerRef) release() {
+	if r.refs.Add(-1) == 0 && r.retired.Load() {
+		r.close()
+	}
+}
+
+// retire 标记为已替换，没有使用者时立即关闭
+func (r *controllerRef) retire() {
+	r.retired.Store(true)
+	if r.refs.Load() == 0 {
+		r.close()
+	}
+}
+
+func (r *controllerRef) close() {
+	r.once.Do(func() {
+		if err := r.ControllerClient.Close(); err != nil {
+			logger.Warn("关闭gRPC连接失败", logging.KeyError, err)
+		}
+	})
+}
+
+// IsPaused 是否处于暂停拉取任务的状态
+func (c *SpiderClient) IsPaused() bool {
+	return c.paused.Load()
+}
+
+// SetPaused 设置暂停状态，已在执行的任务不受影响
+func (c *SpiderClient) SetPaused(paused bool, reason string) {
+	if c.paused.Swap(paused) == paused {
+		return
+	}
+	if paused {
+		logger.Info("主控已暂停爬虫，停止拉取新任务", "reason", reason)
+	} else {
+		logger.Info("主控已启用爬虫，恢复拉取任务", "reason", reason)
+	}
+}
+
+// refreshSpidersStatus 向主控查询爬虫启停状态并同步到本地
+func (c *SpiderClient) refreshSpidersStatus(ctx context.Context) error {
+	ctrl := c.acquire()
+	defer ctrl.release()
+	var status *pb.StatusResponse
 	var err error
+	mode := ctrl.GetMode()
 	if mode == modeGRPC {
-		task, err = c.controller.GetTaskGRPC()
+		status, err = ctrl.GetSpidersStatusGRPC(ctx)
 	} else {
-		task, err = c.controller.GetTaskAPI()
-		if err == nil {
-			c.controller.UpdateLastSuccess()
-			c.checkAndSwitchToGRPC()
+		status, err = ctrl.GetSpidersStatusAPI(ctx)
+	}
+	// 启停状态查询不计入通信模式的健康统计，旧版主控没有该接口时不影响模式切换
+	if errors.Is(err, controller.ErrUnimplemented) {
+		logger.Debug("主控未提供爬虫启停状态接口，保持当前状态", logging.KeyMode, mode, "state", c.stateName())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// 旧版主控或兜底接口未返回启停状态时保持当前状态
+	if status == nil {
+		logger.Debug("主控未返回爬虫启停状态，保持当前状态", "state", c.stateName())
+		return nil
+	}
+	c.SetPaused(!status.Status, status.Message)
+	return nil
+}
+
+// StartBackground 启动后台任务：轮询爬虫启停状态，上报 Agent 状态，重放本地缓存的结果；
+// reportInterval 为 0 时不上报
+func (c *SpiderClient) StartBackground(ctx context.Context, statusInterval, reportInterval time.Duration) {
+	ctx, c.stopWatch = context.WithCancel(ctx)
+	c.startStatusWatcher(ctx, statusInterval)
+	c.startPressureMonitor(ctx)
+	if reportInterval > 0 {
+		c.startStatusReporter(ctx, reportInterval)
+	}
+	if c.spool != nil {
+		go c.spool.Replay(ctx, spoolReplayInterval, c.replaySpooled)
+	}
+}
+
+// startStatusWatcher 定期轮询主控的爬虫启停状态，查询失败时保持当前状态
+func (c *SpiderClient) startStatusWatcher(ctx context.Context, interval time.Duration) {
+	go func() {
+		for {
+			if err := c.refreshSpidersStatus(ctx); err != nil && ctx.Err() == nil {
+				logger.Warn("获取爬虫启停状态失败，保持当前状态", "state", c.stateName(), logging.KeyError, err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(addJitter(interval)):
+			}
 		}
+	}()
+}
+
+// startStatusReporter 定期向主控上报主机资源和任务负载，主控未提供该接口时只提示一次
+func (c *SpiderClient) startStatusReporter(ctx context.Context, interval time.Duration) {
+	collector := sysinfo.NewCollector(version)
+	go func() {
+		var warnedHost, warnedUnimplemented bool
+		for {
+			report := c.statusReport(collector, &warnedHost)
+			err := c.reportStatus(ctx, report)
+			switch {
+			case errors.Is(err, controller.ErrUnimplemented):
+				if !warnedUnimplemented {
+					logger.Info("主控未提供状态上报接口，将继续定期尝试")
+					warnedUnimplemented = true
+				}
+			case err != nil && ctx.Err() == nil:
+				logger.Warn("上报Agent状态失败", logging.KeyError, err)
+			case err == nil:
+				warnedUnimplemented = false
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(addJitter(interval)):
+			}
+		}
+	}()
+}
+
+// statusReport 采集当前的主机资源、运行时和任务负载，主机状态读取失败时只记录一次日志
+func (c *SpiderClient) statusReport(collector *sysinfo.Collector, warnedHost *bool) *pb.StatusReport {
+	host, err := collector.Host()
+	if err != nil && !*warnedHost {
+		logger.Warn("采集主机状态失败，只上报CPU核数", logging.KeyError, err)
+		*warnedHost = true
+	}
+	load := &pb.AgentLoad{
+		InflightTasks: int32(c.limiter.InUse()),
+		MaxConcurrent: int32(c.limiter.Limit()),
+		TransportMode: modeOf(c.transportState()),
+		Paused:        c.IsPaused(),
+	}
+	if c.spool != nil {
+		load.SpoolDepth = int32(c.spool.Depth())
+	}
+	return &pb.StatusReport{
+		Timestamp: time.Now().Unix(),
+		Host:      host,
+		Runtime:   collector.Runtime(),
+		Load:      load,
+	}
+}
+
+// reportStatus 按当前模式上报状态，上报结果不影响通信模式的切换
+func (c *SpiderClient) reportStatus(ctx context.Context, report *pb.StatusReport) error {
+	ctrl := c.acquire()
+	defer ctrl.release()
+	if ctrl.GetMode() == modeGRPC {
+		return ctrl.ReportStatusGRPC(ctx, report)
+	}
+	return ctrl.ReportStatusAPI(ctx, report)
+}
+
+// StopBackground 停止后台任务
+func (c *SpiderClient) StopBackground() {
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+}
+
+// Close 停止后台任务并关闭与主控的连接，需在在途任务结束后调用
+func (c *SpiderClient) Close() {
+	c.StopBackground()
+	c.modeMutex.RLock()
+	defer c.modeMutex.RUnlock()
+	c.controller.retire()
+}
+
+// transportState 当前主控客户端的通信状态
+func (c *SpiderClient) transportState() controller.TransportState {
+	c.modeMutex.RLock()
+	defer c.modeMutex.RUnlock()
+	return c.controller.Transport().State()
+}
+
+// stateName 返回当前启停状态的描述
+func (c *SpiderClient) stateName() string {
+	if c.IsPaused() {
+		return "暂停"
+	}
+	return "运行"
+}
+
+// GetTask 获取任务，ctx 结束时中断请求；ctx 中有 span 时记录通信模式和领取结果
+func (c *SpiderClient) GetTask(ctx context.Context) (*pb.CrawlerTask, error) {
+	ctrl := c.acquire()
+	defer ctrl.release()
+	mode := ctrl.GetMode()
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttributes(logging.KeyMode, mode, logging.KeyFlag, ctrl.GetTaskFlag())
+	var task *pb.CrawlerTask
+	var err error
+	if mode == modeGRPC {
+		task, err = ctrl.GetTaskGRPC(ctx)
+	} else {
+		task, err = ctrl.GetTaskAPI(ctx)
 	}
 	if err != nil {
-		log.Printf("%s 模式获取任务失败: %v", mode, err)
-		// 只有在连接错误时才切换模式，队列为空不切换
-		if !isQueueEmptyError(err) {
-			c.switchMode()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		logger.Debug("获取任务失败", logging.KeyMode, mode, logging.KeyFlag, ctrl.GetTaskFlag(), logging.KeyError, err)
+		// 队列为空说明连接正常
+		if isQueueEmptyError(err) {
+			queueEmptyPolls.WithLabelValues(ctrl.GetTaskFlag()).Inc()
+			span.SetAttributes("queue_empty", true)
+			ctrl.ReportResult(mode, nil)
+		} else {
+			span.RecordError(err)
+			ctrl.ReportResult(mode, err)
 		}
 		return nil, err
 	}
+	ctrl.ReportResult(mode, nil)
+	tasksFetched.WithLabelValues(ctrl.GetTaskFlag()).Inc()
+	span.SetAttributes(logging.KeyTag, task.Tag, "task.trace_id", task.TraceId)
+	// 领取时即校验签名，避免任务在本地队列中等待后超出时间窗口
+	if err := ctrl.VerifyTask(task); err != nil {
+		tasksFailed.WithLabelValues(ctrl.GetTaskFlag(), failureSignature).Inc()
+		span.RecordError(err)
+		return nil, err
+	}
 	return task, nil
 }
 
@@ -116,51 +454,171 @@ func isQueueEmptyError(err error) bool {
 		strings.Contains(errStr, "dynamic 任务队列为空")
 }
 
-// HandleTask 处理任务
-func (c *SpiderClient) HandleTask(task *pb.CrawlerTask) error {
+// HandleTask 处理任务，爬取受任务截止时间约束，ctx 结束时放弃任务且不提交结果；
+// 超过 expires（零值表示不过期）的任务不爬取，报告为 expired；
+// 任务全程使用领取时的主控客户端，期间重建连接不影响该任务
+func (c *SpiderClient) HandleTask(ctx context.Context, task *pb.CrawlerTask, expires time.Time) (err error) {
 	if task == nil {
 		return fmt.Errorf("任务为空")
 	}
+	// 主控下发了链路 ID 时加入该链路，否则作为领取任务的 span 的子 span
+	ctx, span := tracing.StartInTrace(ctx, task.TraceId, "HandleTask", tracing.KindInternal,
+		logging.KeyTag, task.Tag, logging.KeyURL, task.Url, "billing_type", task.BillingType)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	if sc := tracing.SpanContextFromContext(ctx); sc.HasTraceID() {
+		ctx = logging.WithAttrs(ctx, logging.KeyTraceID, sc.TraceID().String())
+	}
+	ctrl := c.acquire()
+	defer ctrl.release()
+	c.modeMutex.RLock()
+	fleetToken := c.fleetToken
+	c.modeMutex.RUnlock()
+	flag := ctrl.GetTaskFlag()
 	if task.Token == "" {
+		tasksFailed.WithLabelValues(flag, failureInvalidTask).Inc()
 		return fmt.Errorf("任务Token为空，可能是服务端问题")
 	}
-	if task.Token != c.controller.Token {
-		return fmt.Errorf("无效的Token: 传入=%s，期望=%s", task.Token, c.controller.Token)
+	if task.Token != ctrl.Token && task.Token != fleetToken {
+		tasksFailed.WithLabelValues(flag, failureInvalidTask).Inc()
+		return fmt.Errorf("无效的Token: 传入=%s，期望=%s", task.Token, ctrl.Token)
 	}
 	if task.Url == "" || task.Tag == "" {
+		tasksFailed.WithLabelValues(flag, failureInvalidTask).Inc()
 		return fmt.Errorf("无效的URL或Tag")
 	}
-	startTime := time.Now()
-	webData, success := c.crawler.FetchWebData(task.Url)
-	runtime := int32(time.Since(startTime).Seconds())
+	var fetched *crawler.FetchResult
+	var coalesced string
+	var elapsed time.Duration
+	if !expires.IsZero() && time.Now().After(expires) {
+		// 等待过久的任务不再爬取，直接报告过期
+		logger.WarnContext(ctx, "任务已过期，不再爬取", "expires", expires)
+		fetched = &crawler.FetchResult{ErrorClass: failureExpired}
+	} else {
+		fetched, coalesced, elapsed = c.crawlTask(ctx, task)
+		if ctx.Err() != nil {
+			return fmt.Errorf("任务被取消: Tag=%s", task.Tag)
+		}
+		crawlDuration.WithLabelValues(flag).Observe(elapsed.Seconds())
+	}
+	if fetched.Success {
+		tasksSucceeded.WithLabelValues(flag).Inc()
+	} else {
+		class := fetched.ErrorClass
+		if class == "" {
+			class = failureUnknown
+		}
+		tasksFailed.WithLabelValues(flag, class).Inc()
+	}
+	runtime := int32(elapsed.Seconds())
 	loc, _ := time.LoadLocation("Asia/Shanghai")
 	beijingTime := time.Now().In(loc)
 	formattedTime := beijingTime.Format("2006-01-02 15:04:05")
-	c.modeMutex.RLock()
-	mode := c.controller.GetMode()
-	c.modeMutex.RUnlock()
-	var err error
+	result := controller.NewCrawlerResult(task, fetched.WebData, fetched.Success, runtime, formattedTime)
+	if sc := tracing.SpanContextFromContext(ctx); result.TraceId == "" && sc.HasTraceID() {
+		result.TraceId = sc.TraceID().String()
+	}
+	span.SetAttributes("success", fetched.Success, logging.KeyErrorClass, fetched.ErrorClass, "coalesced", coalesced)
+	result.AttemptCount = int32(len(fetched.Attempts))
+	result.ThrottleMs = fetched.Throttle.Milliseconds()
+	result.Coalesced = coalesced != ""
+	result.ErrorClass = fetched.ErrorClass
+	for _, a := range fetched.Attempts {
+		result.Attempts = append(result.Attempts, &pb.CrawlAttempt{
+			Error:      a.Error,
+			ErrorClass: a.ErrorClass,
+			StatusCode: int32(a.StatusCode),
+			DurationMs: a.Duration.Milliseconds(),
+			ThrottleMs: a.Throttle.Milliseconds(),
+		})
+	}
+	err = c.submitResult(ctx, ctrl, result)
+	if err != nil && c.spool != nil && !isBusinessError(err) {
+		if spoolErr := c.spool.Put(result); spoolErr != nil {
+			logger.WarnContext(ctx, "结果写入本地缓存失败", logging.KeyError, spoolErr)
+			return err
+		}
+		logger.WarnContext(ctx, "结果提交失败，已写入本地缓存等待重放", "spool_depth", c.spool.Depth())
+		return nil
+	}
+	return err
+}
+
+// crawlTask 在任务截止时间内爬取，相同的请求按设置合并；返回结果、合并的来源和耗时
+func (c *SpiderClient) crawlTask(ctx context.Context, task *pb.CrawlerTask) (*crawler.FetchResult, string, time.Duration) {
+	crawlCtx := ctx
+	if task.Deadline > 0 {
+		var cancel context.CancelFunc
+		crawlCtx, cancel = context.WithDeadline(ctx, time.Unix(task.Deadline, 0))
+		defer cancel()
+	}
+	policy := c.crawler.RetryPolicy().WithOverride(int(task.MaxAttempts), time.Duration(task.RetryBackoffMs)*time.Millisecond)
+	startTime := time.Now()
+	key := coalesceKey(task, c.crawler.RespectsRobots(task.BillingType))
+	fetched, coalesced := c.coalescer.do(crawlCtx, key, func() *crawler.FetchResult {
+		return c.crawler.FetchWebData(crawlCtx, task.Url, policy, task.BillingType)
+	})
+	elapsed := time.Since(startTime)
+	if coalesced != "" {
+		logger.InfoContext(ctx, "与相同请求合并，复用其结果", "source", coalesced, "success", fetched.Success)
+	} else if ctx.Err() == nil {
+		c.observeCrawl(elapsed, fetched)
+	}
+	return fetched, coalesced, elapsed
+}
+
+// submitResult 提交任务结果，当前模式连接失败时改用另一种模式再试一次，不改变当前模式
+func (c *SpiderClient) submitResult(ctx context.Context, ctrl *controllerRef, result *pb.CrawlerResult) error {
+	mode := ctrl.GetMode()
+	err := c.submitResultOnce(ctx, ctrl, result, mode)
+	if err == nil || isBusinessError(err) || ctx.Err() != nil {
+		return err
+	}
 	if mode == modeGRPC {
-		err = c.controller.HandleTaskGRPC(task, webData, success, runtime, formattedTime)
-	} else {
-		err = c.controller.HandleTaskAPI(task, webData, success, runtime, formattedTime)
-		if err == nil {
-			c.controller.UpdateLastSuccess()
-			// 只在API模式成功时检查是否切换到gRPC
-			c.checkAndSwitchToGRPC()
+		return c.submitResultOnce(ctx, ctrl, result, modeAPI)
+	}
+	return c.submitResultOnce(ctx, ctrl, result, modeGRPC)
+}
+
+func (c *SpiderClient) submitResultOnce(ctx context.Context, ctrl *controllerRef, result *pb.CrawlerResult, mode string) (err error) {
+	ctx, span := tracing.Start(ctx, "SubmitResult", tracing.KindClient, logging.KeyMode, mode)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	if mode == modeGRPC {
+		if ctrl.GrpcClient == nil {
+			return fmt.Errorf("未配置gRPC地址")
 		}
+		err = ctrl.HandleTaskGRPC(ctx, result)
+	} else {
+		err = ctrl.HandleTaskAPI(ctx, result)
 	}
 	if err != nil {
-		log.Printf("%s 模式处理任务失败: %v", mode, err)
-		// 只有在连接错误时才切换模式，业务错误不切换
-		if !isBusinessError(err) {
-			c.switchMode()
+		logger.WarnContext(ctx, "提交任务结果失败", logging.KeyMode, mode, logging.KeyError, err)
+		// 业务错误和主动取消不计入连接失败
+		if !isBusinessError(err) && ctx.Err() == nil {
+			ctrl.ReportResult(mode, err)
 		}
 		return err
 	}
+	ctrl.ReportResult(mode, nil)
 	return nil
 }
 
+// replaySpooled 重放本地缓存中的结果，业务错误视为不可重试
+func (c *SpiderClient) replaySpooled(ctx context.Context, result *pb.CrawlerResult) error {
+	ctrl := c.acquire()
+	defer ctrl.release()
+	err := c.submitResult(ctx, ctrl, result)
+	if err != nil && isBusinessError(err) {
+		return fmt.Errorf("%w: %v", spool.ErrPermanent, err)
+	}
+	return err
+}
+
 // isBusinessError 判断是否为业务错误（不需要切换模式的错误）
 func isBusinessError(err error) bool {
 	if err == nil {
@@ -168,72 +626,139 @@ func isBusinessError(err error) bool {
 	}
 	errStr := err.Error()
 	return strings.Contains(errStr, "无效的Token") ||
+		strings.Contains(errStr, "任务签名校验失败") ||
 		strings.Contains(errStr, "无效的URL") ||
 		strings.Contains(errStr, "任务为空") ||
 		strings.Contains(errStr, "任务Token为空")
 }
 
-// checkAndSwitchToGRPC 检查是否需要切换回gRPC模式
-func (c *SpiderClient) checkAndSwitchToGRPC() {
-	c.modeMutex.Lock()
-	defer c.modeMutex.Unlock()
-	if c.controller.GetMode() == modeAPI {
-		c.controller.ModeMutex.RLock()
-		stableTime := time.Since(c.controller.LastSuccess)
-		c.controller.ModeMutex.RUnlock()
-		if stableTime >= 5*time.Minute {
-			// 重新创建整个controller，确保token等参数正确
-			if newController, err := controller.NewControllerClientWithFlag(c.token, c.host, c.grpcPort, c.apiPort, c.taskFlag); err == nil {
-				// 尝试gRPC连接
-				if err := newController.InitGRPCClient(); err == nil {
-					c.controller = newController
-					c.controller.SetMode(modeGRPC)
-					log.Printf("API模式稳定运行5分钟，成功切换回gRPC模式")
-				} else {
-					log.Printf("gRPC连接失败，继续使用API模式: %v", err)
-				}
-			} else {
-				log.Printf("重新创建controller失败: %v", err)
-			}
-		}
+// inflightTasks 记录已领取但尚未提交结果的任务；等待超时后不留下后台等待者，
+// 之后继续领取任务也不受影响
+type inflightTasks struct {
+	mu    sync.Mutex
+	count atomic.Int64
+	idle  chan struct{} // 在途任务全部完成时关闭，有新任务时重建
+}
+
+func (t *inflightTasks) add() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.count.Add(1) == 1 {
+		t.idle = make(chan struct{})
 	}
 }
 
-// switchMode 切换模式
-func (c *SpiderClient) switchMode() {
-	c.modeMutex.Lock()
-	defer c.modeMutex.Unlock()
-	currentMode := c.controller.GetMode()
-	if currentMode == modeGRPC {
-		c.controller.SetMode(modeAPI)
-		log.Printf("切换到 %s 模式", modeAPI)
-	} else {
-		// 重新创建整个controller，确保所有参数正确
-		if newController, err := controller.NewControllerClientWithFlag(c.token, c.host, c.grpcPort, c.apiPort, c.taskFlag); err == nil {
-			if err := newController.InitGRPCClient(); err == nil {
-				c.controller = newController
-				c.controller.SetMode(modeGRPC)
-				log.Printf("切换到 %s 模式", modeGRPC)
-			} else {
-				log.Printf("gRPC 客户端重新初始化失败，保持API模式: %v", err)
-			}
-		} else {
-			log.Printf("重新创建controller失败: %v", err)
-		}
+func (t *inflightTasks) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.count.Add(-1) == 0 {
+		close(t.idle)
 	}
 }
 
-// 异步任务处理函数
-func (c *SpiderClient) handleTaskAsync(ctx context.Context, t *pb.CrawlerTask) {
+// wait 等待在途任务全部完成，超时返回 false
+func (t *inflightTasks) wait(timeout time.Duration) bool {
+	t.mu.Lock()
+	if t.count.Load() == 0 {
+		t.mu.Unlock()
+		return true
+	}
+	idle := t.idle
+	t.mu.Unlock()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 	select {
-	case c.semaphore <- struct{}{}:
-		defer func() { <-c.semaphore }()
-	case <-ctx.Done():
-		return
+	case <-idle:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// dispatchTask 把任务放入本地队列等待执行，并登记为在途任务
+func (c *SpiderClient) dispatchTask(ctx context.Context, t *pb.CrawlerTask) {
+	ctx = c.taskContext(ctx, t)
+	c.inflight.add()
+	c.queue.push(ctx, t)
+}
+
+// runDispatcher 队列中有任务时等待空闲名额，再取出此时优先级最高的任务执行，队列为空时不占用名额；
+// ctx 结束时放弃队列中剩余的任务
+func (c *SpiderClient) runDispatcher(ctx context.Context) {
+	defer func() {
+		for _, q := range c.queue.drain() {
+			logger.WarnContext(q.ctx, "退出时放弃未执行的任务")
+			c.inflight.done()
+		}
+	}()
+	for {
+		if !c.queue.waitReady(ctx) || c.limiter.Acquire(ctx) != nil {
+			return
+		}
+		// 只有调度循环取出任务，等待名额期间队列不会变空
+		q := c.queue.pop()
+		if q == nil {
+			c.limiter.Release()
+			continue
+		}
+		go func() {
+			defer c.inflight.done()
+			slot := &taskSlot{l: c.limiter, held: true}
+			defer slot.release()
+			c.runTask(crawler.WithYielder(q.ctx, slot), q)
+		}()
 	}
-	if err := c.HandleTask(t); err != nil {
-		log.Printf("处理任务失败: %v", err)
+}
+
+// taskContext 在 ctx 中附加任务的日志字段，任务处理过程中的日志都会带上 tag、url 和 flag
+func (c *SpiderClient) taskContext(ctx context.Context, t *pb.CrawlerTask) context.Context {
+	return logging.WithAttrs(ctx, logging.KeyTag, t.Tag, logging.KeyURL, t.Url, logging.KeyFlag, c.currentOptions().TaskFlag)
+}
+
+// Drain 等待在途任务完成并提交结果，超过 timeout 返回 false
+func (c *SpiderClient) Drain(timeout time.Duration) bool {
+	if n := c.inflight.count.Load(); n > 0 {
+		logger.Info("等待在途任务完成", "inflight", n, "timeout", timeout)
 	}
+	return c.inflight.wait(timeout)
+}
+
+// runTask 执行队列中取出的任务，调用方已占用名额并附加在 ctx 中
+func (c *SpiderClient) runTask(ctx context.Context, q *queuedTask) {
+	start := time.Now()
+	queueWait.Observe(start.Sub(q.received).Seconds())
+	if err := c.HandleTask(ctx, q.task, q.expiresAt()); err != nil {
+		logger.WarnContext(ctx, "处理任务失败", logging.KeyDuration, time.Since(start), logging.KeyError, err)
+	}
+}
+
+// setupTracing 按参数启用链路追踪，返回退出前调用的清理函数
+func setupTracing(cfg *settings, agent *pb.AgentInfo) func(context.Context) error {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.traceExporter {
+	case "otlp":
+		exporter, err = tracing.NewOTLPExporter(cfg.traceEndpoint)
+	case "stdout":
+		exporter, err = tracing.NewWriterExporter(os.Stdout)
+	default:
+		return func(context.Context) error { return nil }
+	}
+	if err != nil {
+		logger.Warn("创建追踪导出器失败，不启用链路追踪", logging.KeyError, err)
+		return func(context.Context) error { return nil }
+	}
+	logger.Info("已启用链路追踪", "exporter", cfg.traceExporter, "endpoint", cfg.traceEndpoint, "sample_ratio", cfg.traceSampleRatio)
+	return tracing.Setup(tracing.Options{
+		Exporter:    exporter,
+		SampleRatio: cfg.traceSampleRatio,
+		Resource: []attribute.KeyValue{
+			attribute.String("service.name", "ecsagent"),
+			attribute.String("service.version", agent.Version),
+			attribute.String("service.instance.id", agent.AgentId),
+			attribute.String("host.name", agent.Hostname),
+		},
+	})
 }
 
 // 在指定时长上加入随机抖动，避免集群雪崩
@@ -242,87 +767,215 @@ func addJitter(duration time.Duration) time.Duration {
 	return duration + jitter
 }
 
+// sleepOrReload 休眠指定时长，期间收到重新加载请求时提前返回其原因；ctx 结束时返回 false
+func sleepOrReload(ctx context.Context, d time.Duration, reloadCh <-chan string) (string, bool) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return "", false
+	case reason := <-reloadCh:
+		return reason, true
+	case <-timer.C:
+		return "", true
+	}
+}
+
 func main() {
-	var (
-		token    string
-		host     string
-		grpcPort string
-		apiPort  string
-		taskFlag string
-	)
-	flag.StringVar(&token, "token", "", "爬虫校验的Token")
-	flag.StringVar(&host, "host", "", "主控的IP地址")
-	flag.StringVar(&grpcPort, "grpc-port", "", "主控的gRPC通信端口")
-	flag.StringVar(&apiPort, "api-port", "", "主控的API通信端口")
-	flag.StringVar(&taskFlag, "task-flag", "", "任务类型标识 (可选: cf5s, dynamic, 默认为空)")
-	flag.Parse()
-	if token == "" || host == "" || grpcPort == "" || apiPort == "" {
-		log.Fatal("请提供所有必需的参数: -token, -host, -grpc-port, -api-port")
-	}
-	log.Printf("启动参数: token=%s, host=%s, grpc-port=%s, api-port=%s, task-flag=%s",
-		maskToken(token), host, grpcPort, apiPort, taskFlag)
-	var client *SpiderClient
-	var err error
-	if taskFlag != "" {
-		client, err = NewSpiderClientWithFlag(token, host, grpcPort, apiPort, taskFlag)
-	} else {
-		client, err = NewSpiderClient(token, host, grpcPort, apiPort)
+	cfg, err := loadSettings(os.Args[1:], os.LookupEnv)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fatal("参数无效", err)
 	}
+	if err := logging.Setup(cfg.logFormat, os.Stderr); err != nil {
+		fatal("参数无效", err)
+	}
+	applyLogLevels(cfg)
+	if cfg.configFile != "" {
+		logger.Info("已加载配置文件", "path", cfg.configFile)
+	}
+	if cfg.tlsOptions.Insecure {
+		logger.Warn("已开启 -insecure，Token 和爬取结果将以明文传输")
+	}
+	logger.Info("启动参数", logging.KeyToken, cfg.token, "host", cfg.host, "grpc_port", cfg.grpcPort, "api_port", cfg.apiPort,
+		"api_url", cfg.apiURL, "controller_srv", cfg.srvName, "lb_policy", cfg.lbPolicy, logging.KeyFlag, cfg.taskFlag,
+		"security", cfg.tlsOptions.Mode())
+	logger.Info("gRPC参数", "options", cfg.grpcOpts.String())
+	logger.Info("爬虫参数", "concurrency", cfg.concurrency, "min_concurrent", cfg.minConcurrent, "max_concurrent", cfg.maxConcurrent, "timeout", cfg.crawlOpts.Timeout, "profile", cfg.crawlOpts.Profile,
+		"proxies", len(cfg.crawlOpts.Proxies), "log_level", cfg.logLevel, "log_subsystem_levels", cfg.logSubsystemLevels)
+	ctrlOpts := cfg.controllerOptions()
+	agentID, err := identity.LoadOrCreate(cfg.identityFile)
 	if err != nil {
-		log.Fatalf("创建客户端失败: %v", err)
+		// 以普通用户运行时可能无权写入默认路径，不影响爬取
+		logger.Warn("加载Agent身份失败，使用临时Agent ID，重启后会变化", "identity_file", cfg.identityFile, logging.KeyError, err)
+		agentID = identity.NewEphemeral()
+	}
+	agentInfo := &pb.AgentInfo{
+		AgentId:  agentID.AgentID,
+		Hostname: identity.Hostname(),
+		Version:  version,
+	}
+	if cfg.publicIPURL != "" {
+		ipCtx, ipCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if ip, err := identity.DetectPublicIP(ipCtx, cfg.publicIPURL); err != nil {
+			logger.Warn("查询公网IP失败", logging.KeyError, err)
+		} else {
+			agentInfo.PublicIp = ip
+		}
+		ipCancel()
+	}
+	logger.Info("Agent身份", "agent_id", agentInfo.AgentId, "hostname", agentInfo.Hostname,
+		"version", agentInfo.Version, "public_ip", agentInfo.PublicIp)
+	ctrlOpts.Agent = agentInfo
+	shutdownTracing := setupTracing(cfg, agentInfo)
+	if cfg.hmacSecret != "" {
+		ctrlOpts.Signer = controller.NewSigner(cfg.hmacSecret, cfg.hmacWindow)
+		logger.Info("已启用任务签名校验", "window", cfg.hmacWindow)
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	client, err := NewSpiderClientWithOptions(ctrlOpts)
+	if err != nil {
+		fatal("创建客户端失败", err)
+	}
+	setupConcurrency(client, cfg)
+	client.queue = newTaskQueue(cfg.queueSize)
+	client.coalescer.setWindow(cfg.coalesceWindow)
+	client.crawler.SetRetryPolicy(cfg.retry)
+	if err := client.crawler.SetOptions(cfg.crawlOpts); err != nil {
+		fatal("爬虫参数无效", err)
+	}
+	registerCtx, registerCancel := context.WithTimeout(context.Background(), 20*time.Second)
+	if err := client.Register(registerCtx, agentID); err != nil {
+		logger.Warn("注册Agent失败，使用共享Token继续运行", logging.KeyError, err)
+	}
+	registerCancel()
+	var resultSpool *spool.Spool
+	if cfg.spoolDir != "" {
+		resultSpool, err = spool.Open(cfg.spoolDir, cfg.spoolOpts)
+		if err != nil {
+			logger.Warn("打开本地缓存失败，提交失败的结果将被丢弃", logging.KeyError, err)
+		} else {
+			logger.Info("已打开本地缓存", "dir", cfg.spoolDir, "spool_depth", resultSpool.Depth())
+			client.spool = resultSpool
+		}
+	}
+	// 收到退出信号后停止拉取新任务，已领取的任务在 taskCtx 下继续执行直至超时
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	taskCtx, cancelTasks := context.WithCancel(context.Background())
+	defer cancelTasks()
+	registerClientMetrics(client)
+	var adminServer *http.Server
+	if cfg.adminAddr != "" {
+		adminServer = startAdminServer(cfg.adminAddr, client, cfg.readyDNSName)
+	}
+	client.StartBackground(ctx, cfg.statusInterval, cfg.reportInterval)
+	go client.runDispatcher(taskCtx)
+	reloadCh := watchReload(ctx, cfg.configFile)
 	const (
 		initialBackoff = 6 * time.Second
 		maxBackoff     = 90 * time.Second
 	)
-	for {
+	// 暂停、队列为空和退避期间也及时响应重新加载，不必等到下一次拉取
+	sleep := func(d time.Duration) bool {
+		reason, ok := sleepOrReload(ctx, d, reloadCh)
+		if reason != "" {
+			cfg = reloadSettings(client, cfg, reason)
+		}
+		return ok
+	}
+	for ctx.Err() == nil {
 		backoff := initialBackoff
-		for {
-			task, err := client.GetTask()
+		for ctx.Err() == nil {
+			select {
+			case reason := <-reloadCh:
+				cfg = reloadSettings(client, cfg, reason)
+			default:
+			}
+			if client.IsPaused() {
+				sleep(addJitter(initialBackoff))
+				break
+			}
+			// 本地队列已满时暂停拉取，等待调度出空位
+			if !client.queue.waitSpace(ctx, time.Second) {
+				break
+			}
+			pollCtx, span := tracing.Start(ctx, "GetTask", tracing.KindClient)
+			task, err := client.GetTask(pollCtx)
+			span.End()
+			if ctx.Err() != nil {
+				break
+			}
 			if err == nil {
-				// 添加任务验证日志
-				log.Printf("获取到任务: URL=%s, Token=%s, Tag=%s, BillingType=%s, ReqMethod=%s",
-					task.Url, maskToken(task.Token), task.Tag, task.BillingType, task.ReqMethod)
-				go client.handleTaskAsync(ctx, task)
+				logger.InfoContext(client.taskContext(ctx, task), "获取到任务", logging.KeyToken, task.Token,
+					"billing_type", task.BillingType, "method", task.ReqMethod, "priority", task.Priority)
+				client.dispatchTask(tracing.ContextWithParent(taskCtx, tracing.SpanContextFromContext(pollCtx)), task)
+				break
+			}
+			if errors.Is(err, controller.ErrSignature) {
+				logger.Warn("丢弃签名无效的任务", logging.KeyError, err)
 				break
 			}
 			// 如果是队列为空，减少日志频率
 			if isQueueEmptyError(err) {
 				if backoff == initialBackoff {
-					if taskFlag != "" {
-						log.Printf("%s 任务队列为空，等待新任务...", taskFlag)
-					} else {
-						log.Printf("任务队列为空，等待新任务...")
-					}
+					logger.Info("任务队列为空，等待新任务", logging.KeyFlag, cfg.taskFlag)
 				}
-				time.Sleep(addJitter(initialBackoff))
+				sleep(addJitter(initialBackoff))
+				break
+			}
+			logger.Warn("获取任务失败，稍后重试", logging.KeyFlag, cfg.taskFlag, "backoff", backoff, logging.KeyError, err)
+			if !sleep(addJitter(backoff)) {
 				break
 			}
-			log.Printf("获取任务失败: %v，%v后重试...", err, backoff)
-			time.Sleep(addJitter(backoff))
 			backoff *= 2
 			if backoff > maxBackoff {
 				backoff = maxBackoff
-				if taskFlag != "" {
-					client, err = NewSpiderClientWithFlag(token, host, grpcPort, apiPort, taskFlag)
-				} else {
-					client, err = NewSpiderClient(token, host, grpcPort, apiPort)
-				}
-				if err != nil {
-					log.Fatalf("创建客户端失败: %v", err)
+				if err := client.Reconnect(); err != nil {
+					logger.Warn("重建主控连接失败", logging.KeyError, err)
 				}
 			}
 		}
-		time.Sleep(500 * time.Millisecond)
+		sleep(500 * time.Millisecond)
 	}
+	logger.Info("收到退出信号，停止拉取新任务")
+	if !client.Drain(cfg.shutdownTimeout) {
+		logger.Warn("等待超时，取消剩余的任务", "inflight", client.inflight.count.Load())
+		cancelTasks()
+		client.inflight.wait(5 * time.Second)
+	}
+	client.Close()
+	if adminServer != nil {
+		adminServer.Close()
+	}
+	traceCtx, traceCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(traceCtx); err != nil {
+		logger.Warn("导出剩余追踪数据失败", logging.KeyError, err)
+	}
+	traceCancel()
+	if resultSpool != nil {
+		if n := resultSpool.Depth(); n > 0 {
+			logger.Info("本地缓存中仍有结果，将在下次启动后重放", "spool_depth", n)
+		}
+		resultSpool.Close()
+	}
+	logger.Info("客户端已退出")
 }
 
-// maskToken 遮蔽token用于日志输出
-func maskToken(token string) string {
-	if len(token) <= 4 {
-		return "****"
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
 	}
-	return token[:2] + "****" + token[len(token)-2:]
+	return list
+}
+
+// fatal 输出错误日志后退出
+func fatal(msg string, err error) {
+	logger.Error(msg, logging.KeyError, err)
+	os.Exit(1)
 }
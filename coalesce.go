@@ -0,0 +1,140 @@
+package main
+
+import (
+	"agent/crawler"
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "agent/proto"
+)
+
+// 合并的来源
+const (
+	coalesceInflight = "inflight" // 等待进行中的相同请求
+	coalesceCache    = "cache"    // 窗口内已完成的相同请求
+)
+
+// maxCoalesceEntries 超过该数量时清理过期的结果缓存
+const maxCoalesceEntries = 4096
+
+var tasksCoalesced = promauto.NewCounterVec(prometheus.CounterOpts{Name: "ecsagent_tasks_coalesced_total",
+	Help: "与相同请求合并、未单独爬取的任务数，source 为 inflight 或 cache"}, []string{"source"})
+
+// coalescedCall 进行中的一次爬取，done 关闭后 result 和 ownerEnded 可读
+type coalescedCall struct {
+	done       chan struct{}
+	result     *crawler.FetchResult
+	ownerEnded bool // 发起方的 ctx 在爬取结束时已结束（超过其截止时间或被取消）
+}
+
+// coalescedResult 窗口内缓存的成功结果
+type coalescedResult struct {
+	result  *crawler.FetchResult
+	expires time.Time
+}
+
+// coalescer 合并相同的爬取请求：进行中的共用一次请求，窗口内完成的直接复用结果；窗口为 0 时不合并
+type coalescer struct {
+	mu       sync.Mutex
+	window   time.Duration
+	inflight map[string]*coalescedCall
+	recent   map[string]coalescedResult
+}
+
+func newCoalescer(window time.Duration) *coalescer {
+	return &coalescer{window: window, inflight: map[string]*coalescedCall{}, recent: map[string]coalescedResult{}}
+}
+
+// setWindow 修改结果复用的窗口，为 0 时清空缓存并停止合并
+func (c *coalescer) setWindow(window time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.window = window
+	if window <= 0 {
+		clear(c.recent)
+	}
+}
+
+// coalesceKey 请求的合并键：方法、URL、附加请求头以及是否遵守 robots.txt，任务不携带请求体
+func coalesceKey(task *pb.CrawlerTask, robots bool) string {
+	method := strings.ToUpper(task.ReqMethod)
+	if method == "" {
+		method = "GET"
+	}
+	return strings.Join([]string{method, task.Url, task.ExtraHeader, strconv.FormatBool(robots)}, "\n")
+}
+
+// do 按 key 合并 fetch，返回结果和合并的来源，由本任务爬取时来源为空。
+// 等待进行中的请求时让出任务的全局名额，复用结果后只需提交，不再重新占用；
+// 进行中的请求因发起方自身的截止时间或取消而失败时，本任务重新占用名额并在自己的期限内重新爬取
+func (c *coalescer) do(ctx context.Context, key string, fetch func() *crawler.FetchResult) (*crawler.FetchResult, string) {
+	c.mu.Lock()
+	if c.window <= 0 {
+		c.mu.Unlock()
+		return fetch(), ""
+	}
+	now := time.Now()
+	if r, ok := c.recent[key]; ok && now.Before(r.expires) {
+		c.mu.Unlock()
+		tasksCoalesced.WithLabelValues(coalesceCache).Inc()
+		return r.result, coalesceCache
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		y := crawler.YielderFromContext(ctx)
+		if y != nil {
+			y.Yield()
+		}
+		select {
+		case <-ctx.Done():
+			return endedResult(ctx), ""
+		case <-call.done:
+		}
+		if call.result.Success || !call.ownerEnded || ctx.Err() != nil {
+			tasksCoalesced.WithLabelValues(coalesceInflight).Inc()
+			return call.result, coalesceInflight
+		}
+		if y != nil {
+			if err := y.Resume(ctx); err != nil {
+				return endedResult(ctx), ""
+			}
+		}
+		return c.do(ctx, key, fetch)
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.result = fetch()
+	call.ownerEnded = ctx.Err() != nil
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.result.Success && c.window > 0 {
+		if len(c.recent) >= maxCoalesceEntries {
+			for k, r := range c.recent {
+				if now.After(r.expires) {
+					delete(c.recent, k)
+				}
+			}
+		}
+		c.recent[key] = coalescedResult{result: call.result, expires: time.Now().Add(c.window)}
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return call.result, ""
+}
+
+// endedResult ctx 结束时未爬取的结果
+func endedResult(ctx context.Context) *crawler.FetchResult {
+	class := crawler.ErrorClassCanceled
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		class = crawler.ErrorClassTimeout
+	}
+	return &crawler.FetchResult{ErrorClass: class}
+}
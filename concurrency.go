@@ -0,0 +1,156 @@
+package main
+
+import (
+	"agent/crawler"
+	"agent/limiter"
+	"agent/sysinfo"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// 并发控制方式
+const (
+	concurrencyFixed    = "fixed"    // 上限固定为 -max-concurrent
+	concurrencyAdaptive = "adaptive" // 在 -min-concurrent 和 -max-concurrent 之间自适应
+)
+
+// 本机资源紧张的判断
+const (
+	pressureInterval     = 10 * time.Second
+	pressureCPUPercent   = 90   // CPU 使用率高于该值
+	pressureMemAvailable = 0.05 // 可用内存低于总内存的该比例
+)
+
+// overloadClasses 可能由并发过高引起的爬取错误
+var overloadClasses = map[string]bool{
+	crawler.ErrorClassTimeout:   true,
+	crawler.ErrorClassConnReset: true,
+	crawler.ErrorClassNetwork:   true,
+}
+
+var (
+	concurrencyAdjustments = promauto.NewCounterVec(prometheus.CounterOpts{Name: "ecsagent_concurrency_adjustments_total",
+		Help: "自适应并发调整上限的次数，reason 为 probe、latency、errors、pressure 或 bounds"}, []string{"direction", "reason"})
+	concurrencyBounds = promauto.NewGaugeVec(prometheus.GaugeOpts{Name: "ecsagent_concurrency_bounds",
+		Help: "自适应并发的上下限，固定并发时均为 -max-concurrent"}, []string{"bound"})
+)
+
+// setupConcurrency 按参数设置固定的并发上限或启用自适应并发
+func setupConcurrency(c *SpiderClient, cfg *settings) {
+	if cfg.concurrency != concurrencyAdaptive {
+		c.limiter.SetLimit(cfg.maxConcurrent)
+		setConcurrencyBounds(cfg.maxConcurrent, cfg.maxConcurrent)
+		return
+	}
+	opts := limiter.DefaultAdaptiveOptions()
+	opts.Min, opts.Max = cfg.minConcurrent, cfg.maxConcurrent
+	opts.OnAdjust = func(from, to int, reason string) {
+		direction := "increase"
+		if to < from {
+			direction = "decrease"
+		}
+		concurrencyAdjustments.WithLabelValues(direction, reason).Inc()
+		logger.Debug("并发上限已调整", "from", from, "to", to, "reason", reason)
+	}
+	// 初始上限按 CPU 核数估计，再由延迟和错误率调整
+	c.adaptive = limiter.NewAdaptive(c.limiter, 2*runtime.NumCPU(), opts)
+	setConcurrencyBounds(c.adaptive.Bounds())
+	logger.Info("已启用自适应并发", "min", cfg.minConcurrent, "max", cfg.maxConcurrent, "initial", c.limiter.Limit())
+}
+
+// updateConcurrency 热加载时更新并发上限或自适应的上下限
+func updateConcurrency(c *SpiderClient, next *settings) {
+	if c.adaptive == nil {
+		if previous := c.limiter.SetLimit(next.maxConcurrent); previous != next.maxConcurrent {
+			logger.Info("最大并发任务数已调整", "from", previous, "to", next.maxConcurrent)
+		}
+		setConcurrencyBounds(next.maxConcurrent, next.maxConcurrent)
+		return
+	}
+	c.adaptive.SetBounds(next.minConcurrent, next.maxConcurrent)
+	setConcurrencyBounds(c.adaptive.Bounds())
+}
+
+func setConcurrencyBounds(minLimit, maxLimit int) {
+	concurrencyBounds.WithLabelValues("min").Set(float64(minLimit))
+	concurrencyBounds.WithLabelValues("max").Set(float64(maxLimit))
+}
+
+// taskSlot 任务占用的全局并发名额，爬取等待域名限制时暂时让出，实现 crawler.Yielder；
+// 只在任务自身的 goroutine 中使用
+type taskSlot struct {
+	l    *limiter.Limiter
+	held bool
+}
+
+func (s *taskSlot) Yield() {
+	if s.held {
+		s.held = false
+		s.l.Release()
+	}
+}
+
+func (s *taskSlot) Resume(ctx context.Context) error {
+	if s.held {
+		return nil
+	}
+	if err := s.l.Acquire(ctx); err != nil {
+		return err
+	}
+	s.held = true
+	return nil
+}
+
+// release 任务结束时释放仍占用的名额
+func (s *taskSlot) release() { s.Yield() }
+
+// observeCrawl 把任务的平均单次请求耗时（不含等待域名限制的时间）和是否过载交给自适应并发，需在释放名额前调用
+func (c *SpiderClient) observeCrawl(elapsed time.Duration, fetched *crawler.FetchResult) {
+	// robots.txt 禁止或无法获取时没有请求页面，不计入
+	if c.adaptive == nil || len(fetched.Attempts) == 0 ||
+		fetched.ErrorClass == crawler.ErrorClassRobots || fetched.ErrorClass == crawler.ErrorClassRobotsUnavailable {
+		return
+	}
+	overload := false
+	for _, a := range fetched.Attempts {
+		overload = overload || overloadClasses[a.ErrorClass]
+	}
+	c.adaptive.Observe((elapsed-fetched.Throttle)/time.Duration(len(fetched.Attempts)), overload)
+}
+
+// startPressureMonitor 定期采样本机 CPU 和内存，供自适应并发判断资源是否紧张
+func (c *SpiderClient) startPressureMonitor(ctx context.Context) {
+	if c.adaptive == nil {
+		return
+	}
+	var pressure atomic.Bool
+	c.adaptive.SetPressure(pressure.Load)
+	collector := sysinfo.NewCollector(version)
+	go func() {
+		ticker := time.NewTicker(pressureInterval)
+		defer ticker.Stop()
+		for {
+			// 不支持的平台上读取失败，始终视为不紧张
+			host, err := collector.Host()
+			if err == nil {
+				pressure.Store(underPressure(host.CpuPercent, host.MemAvailableBytes, host.MemTotalBytes))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func underPressure(cpuPercent float64, memAvailable, memTotal uint64) bool {
+	if cpuPercent > pressureCPUPercent {
+		return true
+	}
+	return memTotal > 0 && float64(memAvailable) < float64(memTotal)*pressureMemAvailable
+}
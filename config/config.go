@@ -0,0 +1,240 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Value 配置文件中的单个配置项，字符串、数字、布尔值和字符串数组都会转换为命令行参数的文本形式
+type Value struct {
+	text string
+	set  bool
+}
+
+// UnmarshalJSON 接受字符串、数字、布尔值和数组，数组以逗号拼接
+func (v *Value) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*v = Value{}
+		return nil
+	}
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch x := raw.(type) {
+	case string:
+		v.text = x
+	case float64, bool:
+		v.text = string(data)
+	case []any:
+		items := make([]string, 0, len(x))
+		for _, item := range x {
+			switch item.(type) {
+			case string, float64:
+				items = append(items, fmt.Sprint(item))
+			default:
+				return fmt.Errorf("数组中只能包含字符串或数字")
+			}
+		}
+		v.text = strings.Join(items, ",")
+	default:
+		return fmt.Errorf("不支持的值: %s", data)
+	}
+	v.set = true
+	return nil
+}
+
+// Config 配置文件结构，flag 标签为对应的命令行参数
+type Config struct {
+	Controller struct {
+		Token          Value `json:"token" flag:"token"`
+		Hosts          Value `json:"hosts" flag:"host"`
+		GrpcPort       Value `json:"grpc_port" flag:"grpc-port"`
+		ApiPort        Value `json:"api_port" flag:"api-port"`
+		APIURLs        Value `json:"api_urls" flag:"api-url"`
+		SRV            Value `json:"srv" flag:"controller-srv"`
+		LBPolicy       Value `json:"lb_policy" flag:"lb-policy"`
+		TaskFlag       Value `json:"task_flag" flag:"task-flag"`
+		StatusInterval Value `json:"status_interval" flag:"status-interval"`
+		ReportInterval Value `json:"report_interval" flag:"report-interval"`
+		IdentityFile   Value `json:"identity_file" flag:"identity-file"`
+		PublicIPURL    Value `json:"public_ip_url" flag:"public-ip-url"`
+
+		TLS struct {
+			Insecure   Value `json:"insecure" flag:"insecure"`
+			CA         Value `json:"ca" flag:"tls-ca"`
+			Cert       Value `json:"cert" flag:"tls-cert"`
+			Key        Value `json:"key" flag:"tls-key"`
+			ServerName Value `json:"server_name" flag:"tls-server-name"`
+			Pins       Value `json:"pins" flag:"tls-pin"`
+		} `json:"tls"`
+
+		GRPC struct {
+			KeepaliveTime    Value `json:"keepalive_time" flag:"grpc-keepalive-time"`
+			KeepaliveTimeout Value `json:"keepalive_timeout" flag:"grpc-keepalive-timeout"`
+			KeepaliveIdle    Value `json:"keepalive_idle" flag:"grpc-keepalive-idle"`
+			MaxSendMsg       Value `json:"max_send_msg" flag:"grpc-max-send-msg"`
+			MaxRecvMsg       Value `json:"max_recv_msg" flag:"grpc-max-recv-msg"`
+			RetryMaxAttempts Value `json:"retry_max_attempts" flag:"grpc-retry-max-attempts"`
+			RetryBackoff     Value `json:"retry_backoff" flag:"grpc-retry-backoff"`
+			RetryMaxBackoff  Value `json:"retry_max_backoff" flag:"grpc-retry-max-backoff"`
+		} `json:"grpc"`
+
+		Signing struct {
+			HMACSecret Value `json:"hmac_secret" flag:"hmac-secret"`
+			HMACWindow Value `json:"hmac_window" flag:"hmac-window"`
+		} `json:"signing"`
+	} `json:"controller"`
+
+	Agent struct {
+		Concurrency     Value `json:"concurrency" flag:"concurrency"`
+		MinConcurrent   Value `json:"min_concurrent" flag:"min-concurrent"`
+		MaxConcurrent   Value `json:"max_concurrent" flag:"max-concurrent"`
+		QueueSize       Value `json:"queue_size" flag:"queue-size"`
+		ShutdownTimeout Value `json:"shutdown_timeout" flag:"shutdown-timeout"`
+	} `json:"agent"`
+
+	Crawler struct {
+		Timeout Value `json:"timeout" flag:"crawl-timeout"`
+		Profile Value `json:"profile" flag:"crawler-profile"`
+		Proxies Value `json:"proxies" flag:"proxy"`
+
+		HostLimits    Value `json:"host_limits" flag:"host-limits"`
+		MaxRetryAfter Value `json:"max_retry_after" flag:"max-retry-after"`
+
+		RobotsBillingTypes Value `json:"robots_billing_types" flag:"robots-billing-types"`
+		CoalesceWindow     Value `json:"coalesce_window" flag:"coalesce-window"`
+
+		Retry struct {
+			MaxAttempts Value `json:"max_attempts" flag:"retry-max-attempts"`
+			Backoff     Value `json:"backoff" flag:"retry-backoff"`
+			MaxBackoff  Value `json:"max_backoff" flag:"retry-max-backoff"`
+			Jitter      Value `json:"jitter" flag:"retry-jitter"`
+			On          Value `json:"on" flag:"retry-on"`
+		} `json:"retry"`
+	} `json:"crawler"`
+
+	Spool struct {
+		Dir        Value `json:"dir" flag:"spool-dir"`
+		MaxEntries Value `json:"max_entries" flag:"spool-max-entries"`
+		MaxBytes   Value `json:"max_bytes" flag:"spool-max-bytes"`
+		MaxAge     Value `json:"max_age" flag:"spool-max-age"`
+	} `json:"spool"`
+
+	Logging struct {
+		Level      Value `json:"level" flag:"log-level"`
+		Format     Value `json:"format" flag:"log-format"`
+		Subsystems Value `json:"subsystems" flag:"log-subsystem-levels"`
+	} `json:"logging"`
+
+	Admin struct {
+		Addr         Value `json:"addr" flag:"admin-addr"`
+		ReadyDNSName Value `json:"ready_dns_name" flag:"ready-dns-name"`
+	} `json:"admin"`
+
+	Tracing struct {
+		Exporter    Value `json:"exporter" flag:"trace-exporter"`
+		Endpoint    Value `json:"endpoint" flag:"trace-endpoint"`
+		SampleRatio Value `json:"sample_ratio" flag:"trace-sample-ratio"`
+	} `json:"tracing"`
+}
+
+// Load 读取 JSON 配置文件，未知的配置项视为错误
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	cfg := &Config{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// envPrefix 环境变量名的前缀，避免与 proxy、host 等常见变量冲突
+const envPrefix = "ECSAGENT_"
+
+// EnvName 命令行参数对应的环境变量名，如 grpc-port 对应 ECSAGENT_GRPC_PORT
+func EnvName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Apply 把环境变量和配置文件中的值写入未在命令行中指定的参数，
+// 优先级为 命令行 > 环境变量 > 配置文件；cfg 可以为空
+func Apply(fs *flag.FlagSet, cfg *Config, lookupEnv func(string) (string, bool)) error {
+	fileValues := map[string]entry{}
+	if cfg != nil {
+		if err := collect(reflect.ValueOf(cfg).Elem(), "", fileValues); err != nil {
+			return err
+		}
+		for name := range fileValues {
+			if fs.Lookup(name) == nil {
+				return fmt.Errorf("配置项对应的参数不存在: -%s", name)
+			}
+		}
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] {
+			return
+		}
+		if value, ok := lookupEnv(EnvName(f.Name)); ok {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("环境变量 %s 的值无效: %v", EnvName(f.Name), setErr)
+			}
+			return
+		}
+		if e, ok := fileValues[f.Name]; ok {
+			if setErr := fs.Set(f.Name, e.text); setErr != nil {
+				err = fmt.Errorf("配置项 %s 的值无效: %v", e.path, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// entry 配置文件中已设置的值及其路径
+type entry struct {
+	path string
+	text string
+}
+
+// collect 遍历配置结构，收集已设置的配置项
+func collect(v reflect.Value, prefix string, out map[string]entry) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		path := strings.Split(field.Tag.Get("json"), ",")[0]
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		if field.Type == reflect.TypeOf(Value{}) {
+			value := v.Field(i).Interface().(Value)
+			if value.set {
+				out[field.Tag.Get("flag")] = entry{path: path, text: value.text}
+			}
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			if err := collect(v.Field(i), path, out); err != nil {
+				return err
+			}
+			continue
+		}
+		return fmt.Errorf("不支持的配置项类型: %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestApplyPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"controller":{"token":"file","hosts":["a","b"],"grpc_port":7001,"api_port":"7002","tls":{"insecure":true}},
+		"crawler":{"timeout":"15s"}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var token, host, grpcPort, apiPort string
+	var insecure bool
+	var timeout time.Duration
+	fs.StringVar(&token, "token", "", "")
+	fs.StringVar(&host, "host", "", "")
+	fs.StringVar(&grpcPort, "grpc-port", "", "")
+	fs.StringVar(&apiPort, "api-port", "", "")
+	fs.BoolVar(&insecure, "insecure", false, "")
+	fs.DurationVar(&timeout, "crawl-timeout", time.Second, "")
+	if err := fs.Parse([]string{"-grpc-port", "9001"}); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"ECSAGENT_GRPC_PORT": "8001", "ECSAGENT_API_PORT": "8002", "host": "ignored"}
+	lookupEnv := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	if err := Apply(fs, cfg, lookupEnv); err != nil {
+		t.Fatal(err)
+	}
+	checks := map[string][2]string{
+		"命令行优先":     {grpcPort, "9001"},
+		"环境变量优先于文件": {apiPort, "8002"},
+		"文件中的字符串":   {token, "file"},
+		"文件中的数组":    {host, "a,b"},
+		"文件中的时长":    {timeout.String(), "15s"},
+	}
+	for name, c := range checks {
+		if c[0] != c[1] {
+			t.Errorf("%s: got %q, want %q", name, c[0], c[1])
+		}
+	}
+	if !insecure {
+		t.Error("文件中的布尔值未生效")
+	}
+}
+
+func TestApplyInvalidValue(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("max-concurrent", 10, "")
+	cfg := &Config{}
+	cfg.Agent.MaxConcurrent = Value{text: "many", set: true}
+	if err := Apply(fs, cfg, func(string) (string, bool) { return "", false }); err == nil {
+		t.Fatal("无效的值应返回错误")
+	}
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"agent":{"max_concurent":4}}`), 0o600)
+	if _, err := Load(path); err == nil {
+		t.Fatal("未知的配置项应返回错误")
+	}
+}
@@ -1,13 +1,25 @@
 package controller
 
 import (
+	"agent/logging"
 	pb "agent/proto"
+	"agent/tracing"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/imroc/req/v3"
+	"go.opentelemetry.io/otel/propagation"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
-	"log"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 )
@@ -17,21 +29,62 @@ const (
 	modeAPI  = "api"
 )
 
+var logger = logging.For("controller")
+
 // 与主控通信的客户端结构体
 type ControllerClient struct {
-	Token       string
-	Host        string
-	GrpcPort    string
-	ApiPort     string
-	GrpcClient  pb.SpiderServiceClient
-	Ctx         context.Context
-	Cancel      context.CancelFunc
-	CurrentMode string
-	ModeMutex   sync.RWMutex
-	LastError   time.Time
-	LastSuccess time.Time
-	HttpClient  *req.Client
-	TaskFlag    string
+	Token      string
+	Host       string
+	GrpcPort   string
+	ApiPort    string
+	GrpcClient pb.SpiderServiceClient
+	grpcConn   *grpc.ClientConn
+	Ctx        context.Context
+	Cancel     context.CancelFunc
+	ModeMutex  sync.RWMutex
+	HttpClient *req.Client
+	TaskFlag   string
+	TLS        TLSOptions
+	Signer     *Signer
+	Agent      *pb.AgentInfo
+
+	grpcAddrs []controllerAddr // gRPC 主控地址，多个时通过 resolver 交给 gRPC 负载均衡
+	srvName   string
+	lbPolicy  string
+	resolver  *manual.Resolver
+	api       *apiPool
+	apiBases  []string // 显式配置的 API 地址，为空时由主控地址和 ApiPort 拼接
+	transport *TransportManager
+	grpcOpts  GRPCOptions
+	dialer    func(context.Context, string) (net.Conn, error)
+}
+
+// Options 创建主控客户端的参数
+type Options struct {
+	Token    string
+	Host     string
+	GrpcPort string
+	ApiPort  string
+	TaskFlag string
+	TLS      TLSOptions
+	// Hosts 多个主控地址，共用 GrpcPort/ApiPort，设置后替代 Host
+	Hosts []string
+	// SRVName gRPC 主控的 DNS SRV 记录，设置后替代 Host/Hosts 作为 gRPC 地址
+	SRVName string
+	// LBPolicy gRPC 负载均衡策略: pick_first (按顺序主备) 或 round_robin
+	LBPolicy string
+	// APIBaseURLs 完整的 API 地址（协议、主机、端口和路径前缀），设置后替代 Host/ApiPort
+	APIBaseURLs []string
+	// Signer 不为空时校验任务签名并对结果签名，重建客户端时共用以保留 nonce 记录
+	Signer *Signer
+	// Agent 本机身份，随每次取任务和提交结果发送
+	Agent *pb.AgentInfo
+	// Transport gRPC/API 切换的阈值，零值使用 DefaultTransportConfig
+	Transport TransportConfig
+	// GRPC 保活、消息大小和重试参数，零值使用 DefaultGRPCOptions
+	GRPC GRPCOptions
+
+	dialer func(context.Context, string) (net.Conn, error) // 测试时替换 gRPC 拨号
 }
 
 // TaskFromData API 模式的任务响应结构
@@ -41,13 +94,33 @@ type TaskFromData struct {
 
 // CrawlerTask 任务结构
 type CrawlerTask struct {
-	Token       string `json:"token"`
-	Tag         string `json:"tag"`
-	URL         string `json:"url"`
-	BillingType string `json:"billing_type"`
-	CrawlNum    int    `json:"crawl_num"`
-	ExtraHeader string `json:"extra_header"`
-	ReqMethod   string `json:"req_method"`
+	Token          string `json:"token"`
+	Tag            string `json:"tag"`
+	URL            string `json:"url"`
+	BillingType    string `json:"billing_type"`
+	CrawlNum       int    `json:"crawl_num"`
+	ExtraHeader    string `json:"extra_header"`
+	ReqMethod      string `json:"req_method"`
+	Deadline       int64  `json:"deadline"`
+	MaxAttempts    int    `json:"max_attempts"`
+	RetryBackoffMs int    `json:"retry_backoff_ms"`
+	Timestamp      int64  `json:"timestamp"`
+	Nonce          string `json:"nonce"`
+	Signature      string `json:"signature"`
+	TraceID        string `json:"trace_id"`
+	Priority       int    `json:"priority"` // 越大越先执行
+	TTLMs          int64  `json:"ttl_ms"`   // 领取后在本地队列中的最长等待时间，0 为不限
+}
+
+// StatusFromData API 模式的爬虫状态响应结构
+type StatusFromData struct {
+	Data SpidersStatus `json:"data"`
+}
+
+// SpidersStatus 爬虫启停状态，Status 为 true 表示允许拉取任务，缺少该字段时为空
+type SpidersStatus struct {
+	Status  *bool  `json:"status"`
+	Message string `json:"message"`
 }
 
 // CrawlerResult 结果结构
@@ -62,36 +135,208 @@ type CrawlerResult struct {
 	Success     bool   `json:"success"`
 	ReqMethod   string `json:"req_method"`
 	WebData     string `json:"webdata,omitempty"`
+
+	AttemptCount int            `json:"attempt_count"`
+	Attempts     []CrawlAttempt `json:"attempts,omitempty"`
+	ErrorClass   string         `json:"error_class,omitempty"`
+	Timestamp    int64          `json:"timestamp,omitempty"`
+	Nonce        string         `json:"nonce,omitempty"`
+	Signature    string         `json:"signature,omitempty"`
+	Agent        *AgentInfo     `json:"agent,omitempty"`
+	TraceID      string         `json:"trace_id,omitempty"`
+	ThrottleMs   int64          `json:"throttle_ms,omitempty"`
+	Coalesced    bool           `json:"coalesced,omitempty"`
+}
+
+// StatusReport API 模式上报的 Agent 状态
+type StatusReport struct {
+	Token     string        `json:"token"`
+	Agent     *AgentInfo    `json:"agent,omitempty"`
+	Timestamp int64         `json:"timestamp"`
+	Host      *HostStats    `json:"host,omitempty"`
+	Runtime   *RuntimeStats `json:"runtime,omitempty"`
+	Load      *AgentLoad    `json:"load,omitempty"`
+}
+
+// HostStats 主机资源状态
+type HostStats struct {
+	NumCPU            int     `json:"num_cpu"`
+	Load1             float64 `json:"load1"`
+	Load5             float64 `json:"load5"`
+	Load15            float64 `json:"load15"`
+	CPUPercent        float64 `json:"cpu_percent"`
+	MemTotalBytes     uint64  `json:"mem_total_bytes"`
+	MemAvailableBytes uint64  `json:"mem_available_bytes"`
+	NetRxBytesPerSec  float64 `json:"net_rx_bytes_per_sec"`
+	NetTxBytesPerSec  float64 `json:"net_tx_bytes_per_sec"`
+	TCPConnections    int     `json:"tcp_connections"`
+	UptimeSeconds     int64   `json:"uptime_seconds"`
+}
+
+// RuntimeStats Agent 进程的运行时和构建信息
+type RuntimeStats struct {
+	GoVersion      string `json:"go_version"`
+	BuildVersion   string `json:"build_version"`
+	VcsRevision    string `json:"vcs_revision,omitempty"`
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+	UptimeSeconds  int64  `json:"uptime_seconds"`
+}
+
+// AgentLoad Agent 当前的任务负载
+type AgentLoad struct {
+	InflightTasks int    `json:"inflight_tasks"`
+	MaxConcurrent int    `json:"max_concurrent"`
+	TransportMode string `json:"transport_mode"`
+	SpoolDepth    int    `json:"spool_depth"`
+	Paused        bool   `json:"paused"`
+}
+
+// ErrUnimplemented 主控尚未提供该接口：gRPC 返回 Unimplemented 或 API 返回 404
+var ErrUnimplemented = errors.New("主控未提供该接口")
+
+// ReportStatusFromData API 模式的状态上报响应结构
+type ReportStatusFromData struct {
+	Data struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	} `json:"data"`
+}
+
+// AgentInfo Agent 身份信息
+type AgentInfo struct {
+	AgentID  string `json:"agent_id"`
+	Hostname string `json:"hostname"`
+	Version  string `json:"version"`
+	PublicIP string `json:"public_ip"`
+}
+
+// RegisterFromData API 模式的注册响应结构
+type RegisterFromData struct {
+	Data struct {
+		Success    bool   `json:"success"`
+		Message    string `json:"message"`
+		AgentToken string `json:"agent_token"`
+	} `json:"data"`
+}
+
+// newAgentInfo 转换为 API 模式的身份结构
+func newAgentInfo(agent *pb.AgentInfo) *AgentInfo {
+	if agent == nil {
+		return nil
+	}
+	return &AgentInfo{
+		AgentID:  agent.AgentId,
+		Hostname: agent.Hostname,
+		Version:  agent.Version,
+		PublicIP: agent.PublicIp,
+	}
+}
+
+// CrawlAttempt 单次爬取尝试的结果
+type CrawlAttempt struct {
+	Error      string `json:"error,omitempty"`
+	ErrorClass string `json:"error_class,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	ThrottleMs int64  `json:"throttle_ms,omitempty"`
 }
 
 // NewControllerClient 创建主控客户端
 func NewControllerClient(token, host, grpcPort, apiPort string) (*ControllerClient, error) {
+	return New(Options{Token: token, Host: host, GrpcPort: grpcPort, ApiPort: apiPort})
+}
+
+// New 按参数创建主控客户端
+func New(opts Options) (*ControllerClient, error) {
+	tlsConfig, err := opts.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+	var apiBases []string
+	for _, raw := range opts.APIBaseURLs {
+		baseURL, err := normalizeBaseURL(raw, opts.TLS.Insecure)
+		if err != nil {
+			return nil, err
+		}
+		apiBases = append(apiBases, baseURL)
+	}
+	hosts := opts.Hosts
+	if len(hosts) == 0 && opts.Host != "" {
+		hosts = []string{opts.Host}
+	}
+	lbPolicy := opts.LBPolicy
+	if lbPolicy == "" {
+		lbPolicy = defaultLBPolicy
+	}
 	client := &ControllerClient{
-		Token:       token,
-		Host:        host,
-		GrpcPort:    grpcPort,
-		ApiPort:     apiPort,
-		CurrentMode: modeGRPC,
-		HttpClient:  req.C().SetTimeout(10 * time.Second),
-		LastSuccess: time.Now(),
-		TaskFlag:    "",
-	}
-	// 初始化 gRPC 客户端
+		Token:      opts.Token,
+		Host:       opts.Host,
+		GrpcPort:   opts.GrpcPort,
+		ApiPort:    opts.ApiPort,
+		HttpClient: req.C().SetTimeout(10 * time.Second),
+		TaskFlag:   opts.TaskFlag,
+		TLS:        opts.TLS,
+		Signer:     opts.Signer,
+		Agent:      opts.Agent,
+		srvName:    opts.SRVName,
+		lbPolicy:   lbPolicy,
+		apiBases:   apiBases,
+		grpcOpts:   opts.GRPC,
+		dialer:     opts.dialer,
+	}
+	if client.grpcOpts == (GRPCOptions{}) {
+		client.grpcOpts = DefaultGRPCOptions()
+	}
+	if err := client.grpcOpts.Validate(); err != nil {
+		return nil, err
+	}
+	if len(hosts) > 0 {
+		client.Host = hosts[0]
+	}
+	if opts.SRVName != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		addrs, err := lookupSRV(ctx, opts.SRVName)
+		cancel()
+		if err != nil {
+			logger.Warn("解析SRV记录失败，稍后重试", logging.KeyError, err)
+		}
+		client.grpcAddrs = addrs
+	} else if opts.GrpcPort != "" {
+		for _, host := range hosts {
+			client.grpcAddrs = append(client.grpcAddrs, controllerAddr{Host: host, GrpcPort: opts.GrpcPort})
+		}
+	}
+	client.api = newAPIPool(client.apiURLs())
+	client.Ctx, client.Cancel = context.WithCancel(context.Background())
+	go client.watchEndpoints()
+	if tlsConfig != nil {
+		client.HttpClient.SetTLSClientConfig(tlsConfig)
+	}
+	// 请求体中仍保留 token 字段，兼容只校验请求体的主控
+	client.HttpClient.SetCommonBearerAuthToken(opts.Token)
+	transportCfg := opts.Transport
+	if transportCfg == (TransportConfig{}) {
+		transportCfg = DefaultTransportConfig()
+	}
+	// 初始化 gRPC 客户端，之后一直复用该连接，由状态机决定使用哪种模式
+	var probe func(context.Context) error
 	if err := client.InitGRPCClient(); err != nil {
-		log.Printf("gRPC 客户端初始化失败: %v, 将使用 API 模式", err)
-		client.SetMode(modeAPI)
+		logger.Warn("gRPC 客户端初始化失败，将使用 API 模式", logging.KeyError, err)
+	} else {
+		probe = grpcHealthProbe(client.grpcConn)
+		go client.logWhenReady()
 	}
+	client.transport = NewTransportManager(transportCfg, probe)
+	go client.transport.Run(client.Ctx)
 	return client, nil
 }
 
 // NewControllerClientWithFlag 创建带任务类型标识的主控客户端
 func NewControllerClientWithFlag(token, host, grpcPort, apiPort, taskFlag string) (*ControllerClient, error) {
-	client, err := NewControllerClient(token, host, grpcPort, apiPort)
-	if err != nil {
-		return nil, err
-	}
-	client.TaskFlag = taskFlag
-	return client, nil
+	return New(Options{Token: token, Host: host, GrpcPort: grpcPort, ApiPort: apiPort, TaskFlag: taskFlag})
 }
 
 // SetTaskFlag 设置任务类型标识
@@ -110,49 +355,244 @@ func (c *ControllerClient) GetTaskFlag() string {
 
 // InitGRPCClient 初始化 gRPC 客户端
 func (c *ControllerClient) InitGRPCClient() error {
-	conn, err := grpc.NewClient(
-		fmt.Sprintf("%s:%s", c.Host, c.GrpcPort),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	c.ModeMutex.RLock()
+	addrs := c.grpcAddrs
+	c.ModeMutex.RUnlock()
+	// SRV 首次解析失败时仍创建连接，等刷新到地址后由 resolver 更新
+	if len(addrs) == 0 && c.srvName == "" {
+		return fmt.Errorf("未配置gRPC地址")
+	}
+	transportCreds := insecure.NewCredentials()
+	if !c.TLS.Insecure {
+		tlsConfig, err := c.TLS.Config()
+		if err != nil {
+			return err
+		}
+		transportCreds = credentials.NewTLS(tlsConfig)
+	}
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(transportCreds),
+		grpc.WithPerRPCCredentials(tokenCredentials{token: c.Token, requireTLS: !c.TLS.Insecure}),
+		grpc.WithDefaultServiceConfig(c.grpcOpts.serviceConfig(c.lbPolicy)),
+		grpc.WithChainUnaryInterceptor(metricsInterceptor, tracingInterceptor),
+	}
+	dialOpts = append(dialOpts, c.grpcOpts.dialOptions()...)
+	if c.dialer != nil {
+		dialOpts = append(dialOpts, grpc.WithContextDialer(c.dialer))
+	}
+	var target string
+	var r *manual.Resolver
+	if len(addrs) > 1 || c.srvName != "" {
+		r = newManualResolver(addrs)
+		target = resolverScheme + ":///controller"
+		dialOpts = append(dialOpts, grpc.WithResolvers(r))
+	} else {
+		target = net.JoinHostPort(addrs[0].Host, addrs[0].GrpcPort)
+	}
+	conn, err := grpc.NewClient(target, dialOpts...)
 	if err != nil {
 		return fmt.Errorf("无法连接到gRPC服务器: %v", err)
 	}
+	c.ModeMutex.Lock()
+	c.resolver = r
+	c.ModeMutex.Unlock()
+	c.grpcConn = conn
 	c.GrpcClient = pb.NewSpiderServiceClient(conn)
 	return nil
 }
 
-// SetMode 设置当前模式
-func (c *ControllerClient) SetMode(mode string) {
+// logWhenReady 首次建立 gRPC 连接后打印解析后的 target 和配置的连接参数
+func (c *ControllerClient) logWhenReady() {
+	conn := c.grpcConn
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			logger.Info("gRPC连接已就绪", "target", conn.CanonicalTarget(), "configured_lb", c.lbPolicy, "configured_options", c.grpcOpts.String())
+			return
+		}
+		if !conn.WaitForStateChange(c.Ctx, state) {
+			return
+		}
+	}
+}
+
+// StopWatch 停止主控地址的后台探测和刷新
+func (c *ControllerClient) StopWatch() {
+	if c.Cancel != nil {
+		c.Cancel()
+	}
+}
+
+// Close 停止后台探测并关闭 gRPC 连接
+func (c *ControllerClient) Close() error {
+	c.StopWatch()
+	if c.grpcConn == nil {
+		return nil
+	}
+	return c.grpcConn.Close()
+}
+
+// apiURLs 计算 API 地址列表：优先使用显式配置，否则由主控地址和 ApiPort 拼接
+func (c *ControllerClient) apiURLs() []string {
+	if len(c.apiBases) > 0 {
+		return c.apiBases
+	}
+	if c.ApiPort == "" {
+		return nil
+	}
+	scheme := "https"
+	if c.TLS.Insecure {
+		scheme = "http"
+	}
+	var hosts []string
+	for _, a := range c.grpcAddrs {
+		hosts = append(hosts, a.Host)
+	}
+	if len(hosts) == 0 && c.Host != "" {
+		hosts = []string{c.Host}
+	}
+	var urls []string
+	for _, host := range hosts {
+		urls = append(urls, fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, c.ApiPort)))
+	}
+	return urls
+}
+
+// watchEndpoints 定期刷新 SRV 记录并探测 API 地址的健康状态
+func (c *ControllerClient) watchEndpoints() {
+	probe := time.NewTicker(apiProbeInterval)
+	defer probe.Stop()
+	refresh := time.NewTicker(srvRefreshInterval)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-c.Ctx.Done():
+			return
+		case <-probe.C:
+			if c.api.size() > 1 {
+				c.api.probe(c.Ctx, c.HttpClient)
+			}
+		case <-refresh.C:
+			if c.srvName != "" {
+				c.refreshSRV()
+			}
+		}
+	}
+}
+
+// refreshSRV 重新解析 SRV 记录并更新 gRPC resolver 和 API 地址池
+func (c *ControllerClient) refreshSRV() {
+	ctx, cancel := context.WithTimeout(c.Ctx, 10*time.Second)
+	defer cancel()
+	addrs, err := lookupSRV(ctx, c.srvName)
+	if err != nil {
+		logger.Warn("解析SRV记录失败，继续使用上次的结果", logging.KeyError, err)
+		return
+	}
 	c.ModeMutex.Lock()
-	defer c.ModeMutex.Unlock()
-	c.CurrentMode = mode
-	if mode == modeAPI {
-		c.LastSuccess = time.Now()
+	changed := fmt.Sprint(addrs) != fmt.Sprint(c.grpcAddrs)
+	c.grpcAddrs = addrs
+	r := c.resolver
+	c.ModeMutex.Unlock()
+	if !changed {
+		return
+	}
+	logger.Info("SRV记录已更新", "srv", c.srvName, "addrs", fmt.Sprint(addrs))
+	if r != nil {
+		r.UpdateState(resolverState(addrs))
+	}
+	if len(c.apiBases) == 0 {
+		c.ModeMutex.RLock()
+		urls := c.apiURLs()
+		c.ModeMutex.RUnlock()
+		c.api.set(urls)
 	}
-	c.LastError = time.Now()
+}
+
+// postAPI 依次尝试地址池中的主控，连接失败或返回 5xx 时切换到下一个
+func (c *ControllerClient) postAPI(ctx context.Context, path string, body any) (*req.Response, error) {
+	urls := c.api.order()
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("未配置API地址")
+	}
+	start := time.Now()
+	var lastErr error
+	for _, base := range urls {
+		r := c.HttpClient.R().
+			SetContext(ctx).
+			SetBody(body).
+			SetHeader("Content-Type", "application/json")
+		tracing.Inject(ctx, propagation.HeaderCarrier(r.Headers))
+		resp, err := r.Post(base + path)
+		if err == nil && resp.StatusCode < 500 {
+			c.api.report(base, true)
+			observeAPI(path, start, resp.StatusCode, nil)
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			observeAPI(path, start, 0, ctx.Err())
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			err = fmt.Errorf("HTTP请求失败，状态码: %d", resp.StatusCode)
+		}
+		lastErr = err
+		c.api.report(base, false)
+		if len(urls) > 1 {
+			logger.WarnContext(ctx, "API主控请求失败", "base", base, logging.KeyError, err)
+		}
+	}
+	observeAPI(path, start, 0, lastErr)
+	return nil, lastErr
+}
+
+// normalizeBaseURL 校验 API 基础地址，非 Insecure 模式只允许 HTTPS
+func normalizeBaseURL(raw string, insecure bool) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("无效的API地址: %s", raw)
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !insecure {
+			return "", fmt.Errorf("API地址使用 http 需要同时开启 -insecure: %s", raw)
+		}
+	default:
+		return "", fmt.Errorf("API地址协议不支持: %s", raw)
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return strings.TrimRight(u.String(), "/"), nil
 }
 
 // GetMode 获取当前模式
 func (c *ControllerClient) GetMode() string {
-	c.ModeMutex.RLock()
-	defer c.ModeMutex.RUnlock()
-	return c.CurrentMode
+	return c.transport.Mode()
 }
 
-// UpdateLastSuccess 更新最后一次成功的时间
-func (c *ControllerClient) UpdateLastSuccess() {
-	c.ModeMutex.Lock()
-	defer c.ModeMutex.Unlock()
-	c.LastSuccess = time.Now()
+// ReportResult 向状态机上报一次请求结果，err 为 nil 表示成功；业务错误不应上报
+func (c *ControllerClient) ReportResult(mode string, err error) {
+	c.transport.Report(mode, err)
+}
+
+// Transport 返回 gRPC/API 切换状态机
+func (c *ControllerClient) Transport() *TransportManager {
+	return c.transport
 }
 
 // GetTaskGRPC 通过 gRPC 获取任务
-func (c *ControllerClient) GetTaskGRPC() (*pb.CrawlerTask, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func (c *ControllerClient) GetTaskGRPC(ctx context.Context) (*pb.CrawlerTask, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 	request := &pb.TaskRequest{
 		Token: c.Token,
 		Flag:  c.GetTaskFlag(),
+		Agent: c.Agent,
 	}
 	response, err := c.GrpcClient.GetTask(ctx, request)
 	if err != nil {
@@ -162,16 +602,13 @@ func (c *ControllerClient) GetTaskGRPC() (*pb.CrawlerTask, error) {
 }
 
 // GetTaskAPI 通过 API 获取任务
-func (c *ControllerClient) GetTaskAPI() (*pb.CrawlerTask, error) {
-	url := fmt.Sprintf("http://%s:%s/spiders/getonetask", c.Host, c.ApiPort)
+func (c *ControllerClient) GetTaskAPI(ctx context.Context) (*pb.CrawlerTask, error) {
+	path := "/spiders/getonetask"
 	taskFlag := c.GetTaskFlag()
 	if taskFlag != "" {
-		url += "?flag=" + taskFlag
+		path += "?flag=" + taskFlag
 	}
-	resp, err := c.HttpClient.R().
-		SetBody(map[string]string{"token": c.Token}).
-		SetHeader("Content-Type", "application/json").
-		Post(url)
+	resp, err := c.postAPI(ctx, path, map[string]any{"token": c.Token, "agent": newAgentInfo(c.Agent)})
 	if err != nil {
 		return nil, err
 	}
@@ -190,15 +627,22 @@ func (c *ControllerClient) GetTaskAPI() (*pb.CrawlerTask, error) {
 		CrawlNum:    int32(taskData.Data.CrawlNum),
 		ExtraHeader: taskData.Data.ExtraHeader,
 		ReqMethod:   taskData.Data.ReqMethod,
+		Deadline:    taskData.Data.Deadline,
+
+		MaxAttempts:    int32(taskData.Data.MaxAttempts),
+		RetryBackoffMs: int32(taskData.Data.RetryBackoffMs),
+		Timestamp:      taskData.Data.Timestamp,
+		Nonce:          taskData.Data.Nonce,
+		Signature:      taskData.Data.Signature,
+		TraceId:        taskData.Data.TraceID,
+		Priority:       int32(taskData.Data.Priority),
+		TtlMs:          taskData.Data.TTLMs,
 	}, nil
 }
 
-// HandleTaskGRPC 通过 gRPC 处理任务
-func (c *ControllerClient) HandleTaskGRPC(task *pb.CrawlerTask, webData string, success bool, runtime int32, startTime string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	result := &pb.CrawlerResult{
-		Token:       c.Token,
+// NewCrawlerResult 根据任务和爬取结果构造待提交的结果
+func NewCrawlerResult(task *pb.CrawlerTask, webData string, success bool, runtime int32, startTime string) *pb.CrawlerResult {
+	return &pb.CrawlerResult{
 		Tag:         task.Tag,
 		Url:         task.Url,
 		BillingType: task.BillingType,
@@ -208,40 +652,239 @@ func (c *ControllerClient) HandleTaskGRPC(task *pb.CrawlerTask, webData string,
 		Success:     success,
 		ReqMethod:   task.ReqMethod,
 		WebData:     webData,
+		TraceId:     task.TraceId,
 	}
+}
+
+// HandleTaskGRPC 通过 gRPC 处理任务
+func (c *ControllerClient) HandleTaskGRPC(ctx context.Context, result *pb.CrawlerResult) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	result.Token = c.Token
+	result.Agent = c.Agent
+	c.signResult(result)
 	response, err := c.GrpcClient.HandleTask(ctx, result)
 	if err != nil {
 		return fmt.Errorf("gRPC处理任务失败: %v", err)
 	}
-	log.Printf("任务处理结果 - 成功: %v, 消息: %s", response.Success, response.Message)
+	logger.InfoContext(ctx, "任务处理结果", logging.KeyMode, modeGRPC, "success", response.Success, "message", response.Message)
 	return nil
 }
 
 // HandleTaskAPI 通过 API 处理任务
-func (c *ControllerClient) HandleTaskAPI(task *pb.CrawlerTask, webData string, success bool, runtime int32, startTime string) error {
-	result := CrawlerResult{
-		Token:       c.Token,
-		Tag:         task.Tag,
-		URL:         task.Url,
-		BillingType: task.BillingType,
-		CrawlNum:    int(task.CrawlNum),
-		Runtime:     int(runtime),
-		StartTime:   startTime,
-		Success:     success,
-		ReqMethod:   task.ReqMethod,
-		WebData:     webData,
+func (c *ControllerClient) HandleTaskAPI(ctx context.Context, result *pb.CrawlerResult) error {
+	result.Token = c.Token
+	result.Agent = c.Agent
+	c.signResult(result)
+	body := CrawlerResult{
+		Token:       result.Token,
+		Tag:         result.Tag,
+		URL:         result.Url,
+		BillingType: result.BillingType,
+		CrawlNum:    int(result.CrawlNum),
+		Runtime:     int(result.Runtime),
+		StartTime:   result.StartTime,
+		Success:     result.Success,
+		ReqMethod:   result.ReqMethod,
+		WebData:     result.WebData,
+
+		AttemptCount: int(result.AttemptCount),
+		ErrorClass:   result.ErrorClass,
+		Timestamp:    result.Timestamp,
+		Nonce:        result.Nonce,
+		Signature:    result.Signature,
+		Agent:        newAgentInfo(result.Agent),
+		TraceID:      result.TraceId,
+		ThrottleMs:   result.ThrottleMs,
+		Coalesced:    result.Coalesced,
+	}
+	for _, a := range result.Attempts {
+		body.Attempts = append(body.Attempts, CrawlAttempt{
+			Error:      a.Error,
+			ErrorClass: a.ErrorClass,
+			StatusCode: int(a.StatusCode),
+			DurationMs: a.DurationMs,
+			ThrottleMs: a.ThrottleMs,
+		})
 	}
-	url := fmt.Sprintf("http://%s:%s/spiders/handletask", c.Host, c.ApiPort)
-	resp, err := c.HttpClient.R().
-		SetBody(result).
-		SetHeader("Content-Type", "application/json").
-		Post(url)
+	resp, err := c.postAPI(ctx, "/spiders/handletask", body)
 	if err != nil {
 		return fmt.Errorf("发送请求失败: %v", err)
 	}
 	if !resp.IsSuccessState() {
 		return fmt.Errorf("HTTP请求失败，状态码: %d", resp.StatusCode)
 	}
-	log.Printf("API任务处理结果: %s", resp.String())
+	logger.DebugContext(ctx, "任务处理结果", logging.KeyMode, modeAPI, "response", resp.String())
+	return nil
+}
+
+// signResult 配置了签名密钥时为结果签名
+func (c *ControllerClient) signResult(result *pb.CrawlerResult) {
+	if c.Signer != nil {
+		c.Signer.SignResult(result)
+	}
+}
+
+// VerifyTask 配置了签名密钥时校验任务签名
+func (c *ControllerClient) VerifyTask(task *pb.CrawlerTask) error {
+	if c.Signer == nil {
+		return nil
+	}
+	return c.Signer.VerifyTask(task)
+}
+
+// GetSpidersStatusGRPC 通过 gRPC 获取主控下发的爬虫启停状态
+func (c *ControllerClient) GetSpidersStatusGRPC(ctx context.Context) (*pb.StatusResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	response, err := c.GrpcClient.GetSpidersStatus(ctx, &pb.StatusRequest{Token: c.Token})
+	if status.Code(err) == codes.Unimplemented {
+		return nil, ErrUnimplemented
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gRPC获取爬虫状态失败: %v", err)
+	}
+	return response, nil
+}
+
+// GetSpidersStatusAPI 通过 API 获取主控下发的爬虫启停状态，响应中没有 status 字段时返回 nil 表示不改变状态
+func (c *ControllerClient) GetSpidersStatusAPI(ctx context.Context) (*pb.StatusResponse, error) {
+	resp, err := c.postAPI(ctx, "/spiders/getstatus", map[string]string{"token": c.Token})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrUnimplemented
+	}
+	if !resp.IsSuccessState() {
+		return nil, fmt.Errorf("HTTP请求失败，状态码: %d", resp.StatusCode)
+	}
+	var statusData StatusFromData
+	if err := resp.UnmarshalJson(&statusData); err != nil {
+		return nil, err
+	}
+	if statusData.Data.Status == nil {
+		return nil, nil
+	}
+	return &pb.StatusResponse{
+		Status:  *statusData.Data.Status,
+		Message: statusData.Data.Message,
+	}, nil
+}
+
+// RegisterGRPC 通过 gRPC 向主控注册本机身份，返回主控下发的专属凭证（可能为空）
+func (c *ControllerClient) RegisterGRPC(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	response, err := c.GrpcClient.Register(ctx, &pb.RegisterRequest{Token: c.Token, Agent: c.Agent})
+	if err != nil {
+		return "", fmt.Errorf("gRPC注册失败: %v", err)
+	}
+	if !response.Success {
+		return "", fmt.Errorf("主控拒绝注册: %s", response.Message)
+	}
+	return response.AgentToken, nil
+}
+
+// RegisterAPI 通过 API 向主控注册本机身份，返回主控下发的专属凭证（可能为空）
+func (c *ControllerClient) RegisterAPI(ctx context.Context) (string, error) {
+	resp, err := c.postAPI(ctx, "/spiders/register", map[string]any{"token": c.Token, "agent": newAgentInfo(c.Agent)})
+	if err != nil {
+		return "", err
+	}
+	if !resp.IsSuccessState() {
+		return "", fmt.Errorf("HTTP请求失败，状态码: %d", resp.StatusCode)
+	}
+	var registerData RegisterFromData
+	if err := resp.UnmarshalJson(&registerData); err != nil {
+		return "", err
+	}
+	if !registerData.Data.Success {
+		return "", fmt.Errorf("主控拒绝注册: %s", registerData.Data.Message)
+	}
+	return registerData.Data.AgentToken, nil
+}
+
+// ReportStatusGRPC 通过 gRPC 上报 Agent 状态，Token 和 Agent 由客户端填入
+func (c *ControllerClient) ReportStatusGRPC(ctx context.Context, report *pb.StatusReport) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	report.Token = c.Token
+	report.Agent = c.Agent
+	response, err := c.GrpcClient.ReportStatus(ctx, report)
+	if status.Code(err) == codes.Unimplemented {
+		return ErrUnimplemented
+	}
+	if err != nil {
+		return fmt.Errorf("gRPC上报状态失败: %v", err)
+	}
+	if !response.Success {
+		return fmt.Errorf("主控拒绝状态上报: %s", response.Message)
+	}
+	return nil
+}
+
+// ReportStatusAPI 通过 API 上报 Agent 状态，Token 和 Agent 由客户端填入
+func (c *ControllerClient) ReportStatusAPI(ctx context.Context, report *pb.StatusReport) error {
+	report.Token = c.Token
+	report.Agent = c.Agent
+	body := StatusReport{
+		Token:     report.Token,
+		Agent:     newAgentInfo(report.Agent),
+		Timestamp: report.Timestamp,
+	}
+	if h := report.Host; h != nil {
+		body.Host = &HostStats{
+			NumCPU:            int(h.NumCpu),
+			Load1:             h.Load1,
+			Load5:             h.Load5,
+			Load15:            h.Load15,
+			CPUPercent:        h.CpuPercent,
+			MemTotalBytes:     h.MemTotalBytes,
+			MemAvailableBytes: h.MemAvailableBytes,
+			NetRxBytesPerSec:  h.NetRxBytesPerSec,
+			NetTxBytesPerSec:  h.NetTxBytesPerSec,
+			TCPConnections:    int(h.TcpConnections),
+			UptimeSeconds:     h.UptimeSeconds,
+		}
+	}
+	if r := report.Runtime; r != nil {
+		body.Runtime = &RuntimeStats{
+			GoVersion:      r.GoVersion,
+			BuildVersion:   r.BuildVersion,
+			VcsRevision:    r.VcsRevision,
+			Goroutines:     int(r.Goroutines),
+			HeapAllocBytes: r.HeapAllocBytes,
+			SysBytes:       r.SysBytes,
+			NumGC:          r.NumGc,
+			UptimeSeconds:  r.UptimeSeconds,
+		}
+	}
+	if l := report.Load; l != nil {
+		body.Load = &AgentLoad{
+			InflightTasks: int(l.InflightTasks),
+			MaxConcurrent: int(l.MaxConcurrent),
+			TransportMode: l.TransportMode,
+			SpoolDepth:    int(l.SpoolDepth),
+			Paused:        l.Paused,
+		}
+	}
+	resp, err := c.postAPI(ctx, "/spiders/status", body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrUnimplemented
+	}
+	if !resp.IsSuccessState() {
+		return fmt.Errorf("HTTP请求失败，状态码: %d", resp.StatusCode)
+	}
+	var reportData ReportStatusFromData
+	if err := resp.UnmarshalJson(&reportData); err != nil {
+		return err
+	}
+	if !reportData.Data.Success {
+		return fmt.Errorf("主控拒绝状态上报: %s", reportData.Data.Message)
+	}
 	return nil
 }
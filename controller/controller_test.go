@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetSpidersStatusAPI(t *testing.T) {
+	body := `{"data":{"status":false,"message":"维护"}}`
+	code := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+	c, err := New(Options{Token: "token", TLS: TLSOptions{Insecure: true}, APIBaseURLs: []string{ts.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	status, err := c.GetSpidersStatusAPI(context.Background())
+	if err != nil || status == nil || status.Status || status.Message != "维护" {
+		t.Fatalf("应返回暂停状态: %+v %v", status, err)
+	}
+	// 旧版主控不返回 status 时不改变状态
+	body = `{"data":{}}`
+	if status, err := c.GetSpidersStatusAPI(context.Background()); err != nil || status != nil {
+		t.Fatalf("缺少 status 时应返回 nil: %+v %v", status, err)
+	}
+	// 没有该接口的旧版主控
+	code = http.StatusNotFound
+	if _, err := c.GetSpidersStatusAPI(context.Background()); !errors.Is(err, ErrUnimplemented) {
+		t.Fatalf("404 应返回 ErrUnimplemented, got %v", err)
+	}
+}
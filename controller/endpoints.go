@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"agent/logging"
+	"context"
+	"fmt"
+	"github.com/imroc/req/v3"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	resolverScheme      = "ecsagent"
+	srvRefreshInterval  = time.Minute
+	apiProbeInterval    = 30 * time.Second
+	defaultLBPolicy     = "pick_first"
+	apiProbePath        = "/spiders/health"
+	apiHealthyStatusMax = 499
+)
+
+// controllerAddr 单个主控的地址
+type controllerAddr struct {
+	Host     string
+	GrpcPort string
+}
+
+// lookupSRV 解析 SRV 记录，返回按优先级和权重排序的主控地址
+func lookupSRV(ctx context.Context, name string) ([]controllerAddr, error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("解析SRV记录失败: %v", err)
+	}
+	addrs := make([]controllerAddr, 0, len(records))
+	for _, r := range records {
+		addrs = append(addrs, controllerAddr{
+			Host:     strings.TrimSuffix(r.Target, "."),
+			GrpcPort: strconv.Itoa(int(r.Port)),
+		})
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("SRV记录为空: %s", name)
+	}
+	return addrs, nil
+}
+
+// resolverState 把主控地址转换为 gRPC resolver 状态，ServerName 用于 TLS 校验
+func resolverState(addrs []controllerAddr) resolver.State {
+	state := resolver.State{}
+	for _, a := range addrs {
+		state.Addresses = append(state.Addresses, resolver.Address{
+			Addr:       net.JoinHostPort(a.Host, a.GrpcPort),
+			ServerName: a.Host,
+		})
+	}
+	return state
+}
+
+// newManualResolver 创建携带主控地址列表的 resolver，供 gRPC 负载均衡使用
+func newManualResolver(addrs []controllerAddr) *manual.Resolver {
+	r := manual.NewBuilderWithScheme(resolverScheme)
+	r.InitialState(resolverState(addrs))
+	return r
+}
+
+// apiPool API 模式的主控地址池，按配置顺序优先使用靠前的健康地址
+type apiPool struct {
+	mu      sync.Mutex
+	urls    []string
+	healthy []bool
+	current int
+}
+
+func newAPIPool(urls []string) *apiPool {
+	p := &apiPool{}
+	p.set(urls)
+	return p
+}
+
+// set 替换地址列表，保留仍存在的地址的健康状态
+func (p *apiPool) set(urls []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	previous := make(map[string]bool, len(p.urls))
+	for i, u := range p.urls {
+		previous[u] = p.healthy[i]
+	}
+	currentURL := ""
+	if p.current < len(p.urls) {
+		currentURL = p.urls[p.current]
+	}
+	p.urls = urls
+	p.healthy = make([]bool, len(urls))
+	p.current = 0
+	for i, u := range urls {
+		healthy, ok := previous[u]
+		p.healthy[i] = !ok || healthy
+		if u == currentURL {
+			p.current = i
+		}
+	}
+}
+
+// order 返回本次请求尝试的地址顺序：从当前地址开始的健康地址优先，不健康的地址排在最后
+func (p *apiPool) order() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.urls)
+	list := make([]string, 0, n)
+	var unhealthy []string
+	for i := 0; i < n; i++ {
+		idx := (p.current + i) % n
+		if p.healthy[idx] {
+			list = append(list, p.urls[idx])
+		} else {
+			unhealthy = append(unhealthy, p.urls[idx])
+		}
+	}
+	return append(list, unhealthy...)
+}
+
+// report 记录一次请求的结果，失败时切换到下一个地址
+func (p *apiPool) report(url string, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, u := range p.urls {
+		if u != url {
+			continue
+		}
+		p.healthy[i] = ok
+		if ok && i != p.current && !p.healthy[p.current] {
+			logger.Info("API主控切换", "from", p.urls[p.current], "to", u)
+			p.current = i
+		}
+		return
+	}
+}
+
+// preferHealthy 探测结束后切回最靠前的健康地址（主备场景下主控恢复后回切）
+func (p *apiPool) preferHealthy() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, ok := range p.healthy {
+		if ok {
+			if i != p.current {
+				logger.Info("API主控切换", "from", p.urls[p.current], "to", p.urls[i])
+				p.current = i
+			}
+			return
+		}
+	}
+}
+
+// snapshot 返回地址列表副本
+func (p *apiPool) snapshot() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.urls...)
+}
+
+// size 地址数量
+func (p *apiPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.urls)
+}
+
+// probe 探测所有 API 地址，能返回非 5xx 响应即视为可用
+func (p *apiPool) probe(ctx context.Context, client *req.Client) {
+	for _, base := range p.snapshot() {
+		probeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		resp, err := client.R().SetContext(probeCtx).Get(base + apiProbePath)
+		cancel()
+		ok := err == nil && resp.StatusCode <= apiHealthyStatusMax
+		if !ok && ctx.Err() == nil {
+			logger.Warn("API主控健康检查失败", "base", base, logging.KeyError, probeError(resp, err))
+		}
+		p.report(base, ok)
+	}
+	p.preferHealthy()
+}
+
+func probeError(resp *req.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("状态码: %d", resp.StatusCode)
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"time"
+)
+
+// GRPCOptions gRPC 连接的保活、消息大小和重试参数
+type GRPCOptions struct {
+	KeepaliveTime       time.Duration // 连接空闲多久后发送 ping，0 表示不发送
+	KeepaliveTimeout    time.Duration // 等待 ping 响应的超时
+	PermitWithoutStream bool          // 没有进行中的调用时也发送 ping，需主控允许
+	MaxSendMsgSize      int           // 单条消息发送上限（字节）
+	MaxRecvMsgSize      int           // 单条消息接收上限（字节）
+	RetryMaxAttempts    int           // GetTask 在 UNAVAILABLE 时的最多尝试次数，小于 2 表示不重试
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
+}
+
+// DefaultGRPCOptions 默认的 gRPC 参数
+func DefaultGRPCOptions() GRPCOptions {
+	return GRPCOptions{
+		KeepaliveTime:       5 * time.Minute,
+		KeepaliveTimeout:    20 * time.Second,
+		MaxSendMsgSize:      16 << 20,
+		MaxRecvMsgSize:      16 << 20,
+		RetryMaxAttempts:    3,
+		RetryInitialBackoff: 500 * time.Millisecond,
+		RetryMaxBackoff:     5 * time.Second,
+	}
+}
+
+// Validate 检查参数是否有效
+func (o GRPCOptions) Validate() error {
+	if o.KeepaliveTime < 0 || o.KeepaliveTimeout < 0 {
+		return fmt.Errorf("gRPC keepalive 时间不能为负数")
+	}
+	if o.MaxSendMsgSize <= 0 || o.MaxRecvMsgSize <= 0 {
+		return fmt.Errorf("gRPC 消息大小上限必须大于0")
+	}
+	// gRPC 会把超过 5 的重试次数截断为 5
+	if o.RetryMaxAttempts > 5 {
+		return fmt.Errorf("gRPC 重试次数最多为5")
+	}
+	if o.RetryMaxAttempts >= 2 && (o.RetryInitialBackoff <= 0 || o.RetryMaxBackoff < o.RetryInitialBackoff) {
+		return fmt.Errorf("gRPC 重试等待时间无效: %v ~ %v", o.RetryInitialBackoff, o.RetryMaxBackoff)
+	}
+	return nil
+}
+
+// String 返回参数的简要描述，用于日志
+func (o GRPCOptions) String() string {
+	keepaliveDesc := "off"
+	if o.KeepaliveTime > 0 {
+		keepaliveDesc = fmt.Sprintf("%v/%v", o.KeepaliveTime, o.KeepaliveTimeout)
+		if o.PermitWithoutStream {
+			keepaliveDesc += "+idle"
+		}
+	}
+	retryDesc := "off"
+	if o.RetryMaxAttempts >= 2 {
+		retryDesc = fmt.Sprintf("%d次 %v~%v", o.RetryMaxAttempts, o.RetryInitialBackoff, o.RetryMaxBackoff)
+	}
+	return fmt.Sprintf("keepalive=%s, max-send=%dB, max-recv=%dB, retry=%s",
+		keepaliveDesc, o.MaxSendMsgSize, o.MaxRecvMsgSize, retryDesc)
+}
+
+// dialOptions 保活和消息大小对应的拨号参数
+func (o GRPCOptions) dialOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallSendMsgSize(o.MaxSendMsgSize),
+			grpc.MaxCallRecvMsgSize(o.MaxRecvMsgSize),
+		),
+	}
+	if o.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                o.KeepaliveTime,
+			Timeout:             o.KeepaliveTimeout,
+			PermitWithoutStream: o.PermitWithoutStream,
+		}))
+	}
+	return opts
+}
+
+// serviceConfig 生成包含负载均衡策略和 GetTask 重试策略的 service config。
+// HandleTask 不自动重试：重发会带着相同的签名时间戳和 nonce，被主控当作重放拒绝；
+// 提交失败由调用方重新签名后切换模式或写入 spool
+func (o GRPCOptions) serviceConfig(lbPolicy string) string {
+	config := map[string]any{
+		"loadBalancingConfig": []any{map[string]any{lbPolicy: map[string]any{}}},
+	}
+	if o.RetryMaxAttempts >= 2 {
+		config["methodConfig"] = []any{map[string]any{
+			"name": []any{
+				map[string]string{"service": spiderService, "method": "GetTask"},
+			},
+			"retryPolicy": map[string]any{
+				"maxAttempts":          o.RetryMaxAttempts,
+				"initialBackoff":       durationJSON(o.RetryInitialBackoff),
+				"maxBackoff":           durationJSON(o.RetryMaxBackoff),
+				"backoffMultiplier":    2,
+				"retryableStatusCodes": []string{"UNAVAILABLE"},
+			},
+		}}
+	}
+	data, _ := json.Marshal(config)
+	return string(data)
+}
+
+// durationJSON service config 中的时长格式，如 "0.5s"
+func durationJSON(d time.Duration) string {
+	return fmt.Sprintf("%gs", d.Seconds())
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestServiceConfigAccepted(t *testing.T) {
+	for _, policy := range []string{"pick_first", "round_robin"} {
+		for _, attempts := range []int{0, 3} {
+			opts := DefaultGRPCOptions()
+			opts.RetryMaxAttempts = attempts
+			conn, err := grpc.NewClient("passthrough:///bufnet",
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithDefaultServiceConfig(opts.serviceConfig(policy)))
+			if err != nil {
+				t.Fatalf("policy=%s attempts=%d: %v\n%s", policy, attempts, err, opts.serviceConfig(policy))
+			}
+			conn.Close()
+		}
+	}
+}
+
+func TestHandleTaskNotRetried(t *testing.T) {
+	opts := DefaultGRPCOptions()
+	opts.RetryMaxAttempts = 3
+	config := opts.serviceConfig("pick_first")
+	if !strings.Contains(config, `"GetTask"`) || strings.Contains(config, `"HandleTask"`) {
+		t.Fatalf("只应重试 GetTask，HandleTask 重发会被当作重放: %s", config)
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"agent/tracing"
+	"context"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// lastContact 最近一次收到主控响应的时间（UnixNano），重建连接后仍保留
+var lastContact atomic.Int64
+
+// LastContact 最近一次收到主控响应的时间，从未收到时为零值
+func LastContact() time.Time {
+	if n := lastContact.Load(); n > 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+// rpcBuckets 与主控通信耗时的分桶，单位秒
+var rpcBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	rpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{Name: "ecsagent_controller_rpc_duration_seconds",
+		Help: "与主控通信的请求耗时", Buckets: rpcBuckets}, []string{"transport", "method"})
+	rpcErrors = promauto.NewCounterVec(prometheus.CounterOpts{Name: "ecsagent_controller_rpc_errors_total",
+		Help: "与主控通信失败的请求数，code 为 gRPC 状态码、HTTP 状态码或 error"}, []string{"transport", "method", "code"})
+	transportSwitches = promauto.NewCounterVec(prometheus.CounterOpts{Name: "ecsagent_transport_switches_total",
+		Help: "通信状态切换次数"}, []string{"from", "to"})
+)
+
+// metricsInterceptor 记录 gRPC 调用的耗时和失败
+func metricsInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	code := ""
+	if err != nil {
+		code = status.Code(err).String()
+	}
+	// 主控返回的业务错误（如队列为空）同样说明主控可达
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+	default:
+		lastContact.Store(time.Now().UnixNano())
+	}
+	observeRPC(modeGRPC, path.Base(method), start, code)
+	return err
+}
+
+// observeAPI 记录一次 API 请求，path 中的查询参数不计入方法名
+func observeAPI(apiPath string, start time.Time, statusCode int, err error) {
+	apiPath, _, _ = strings.Cut(apiPath, "?")
+	code := ""
+	switch {
+	case err != nil:
+		code = "error"
+	case statusCode >= 300:
+		code = strconv.Itoa(statusCode)
+	}
+	if err == nil {
+		lastContact.Store(time.Now().UnixNano())
+	}
+	observeRPC(modeAPI, path.Base(apiPath), start, code)
+}
+
+func observeRPC(transport, method string, start time.Time, code string) {
+	rpcDuration.WithLabelValues(transport, method).Observe(time.Since(start).Seconds())
+	if code != "" {
+		rpcErrors.WithLabelValues(transport, method, code).Inc()
+	}
+}
+
+// tracingInterceptor 把当前链路的 traceparent 放入 gRPC metadata
+func tracingInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	carrier := propagation.MapCarrier{}
+	tracing.Inject(ctx, carrier)
+	for key, value := range carrier {
+		ctx = metadata.AppendToOutgoingContext(ctx, key, value)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
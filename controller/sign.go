@@ -0,0 +1,148 @@
+package controller
+
+import (
+	pb "agent/proto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSignature 任务签名、时间戳或 nonce 校验失败
+var ErrSignature = errors.New("任务签名校验失败")
+
+// Signer 使用共享密钥对任务和结果做 HMAC-SHA256 签名，并拒绝时间窗口内的重放
+type Signer struct {
+	secret []byte
+	window time.Duration
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewSigner 创建签名器，window 为允许的时间偏差和防重放窗口
+func NewSigner(secret string, window time.Duration) *Signer {
+	return &Signer{
+		secret: []byte(secret),
+		window: window,
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// taskPayload 任务的待签名内容，各字段按固定顺序以换行拼接
+func taskPayload(t *pb.CrawlerTask) string {
+	return strings.Join([]string{
+		"task",
+		t.Token,
+		t.Tag,
+		t.Url,
+		t.BillingType,
+		strconv.Itoa(int(t.CrawlNum)),
+		t.ExtraHeader,
+		t.ReqMethod,
+		strconv.FormatInt(t.Deadline, 10),
+		strconv.Itoa(int(t.MaxAttempts)),
+		strconv.Itoa(int(t.RetryBackoffMs)),
+		t.TraceId,
+		strconv.Itoa(int(t.Priority)),
+		strconv.FormatInt(t.TtlMs, 10),
+		strconv.FormatInt(t.Timestamp, 10),
+		t.Nonce,
+	}, "\n")
+}
+
+// resultPayload 结果的待签名内容，网页数据以其 SHA256 参与签名
+func resultPayload(r *pb.CrawlerResult) string {
+	webData := sha256.Sum256([]byte(r.WebData))
+	return strings.Join([]string{
+		"result",
+		r.Token,
+		r.Tag,
+		r.Url,
+		r.BillingType,
+		strconv.Itoa(int(r.CrawlNum)),
+		strconv.Itoa(int(r.Runtime)),
+		r.StartTime,
+		strconv.FormatBool(r.Success),
+		r.ReqMethod,
+		hex.EncodeToString(webData[:]),
+		strconv.Itoa(int(r.AttemptCount)),
+		r.ErrorClass,
+		attemptsPayload(r.Attempts),
+		strconv.FormatInt(r.ThrottleMs, 10),
+		strconv.FormatBool(r.Coalesced),
+		r.TraceId,
+		r.GetAgent().GetAgentId(),
+		strconv.FormatInt(r.Timestamp, 10),
+		r.Nonce,
+	}, "\n")
+}
+
+// attemptsPayload 各次尝试以 ; 拼接，每次为 error_class/status_code/duration_ms/throttle_ms/hex(sha256(error))
+func attemptsPayload(attempts []*pb.CrawlAttempt) string {
+	parts := make([]string, len(attempts))
+	for i, a := range attempts {
+		errHash := sha256.Sum256([]byte(a.Error))
+		parts[i] = strings.Join([]string{
+			a.ErrorClass,
+			strconv.Itoa(int(a.StatusCode)),
+			strconv.FormatInt(a.DurationMs, 10),
+			strconv.FormatInt(a.ThrottleMs, 10),
+			hex.EncodeToString(errHash[:]),
+		}, "/")
+	}
+	return strings.Join(parts, ";")
+}
+
+func (s *Signer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newNonce 生成随机 nonce
+func newNonce() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// SignResult 为结果填充时间戳、nonce 和签名，每次提交都需重新签名
+func (s *Signer) SignResult(r *pb.CrawlerResult) {
+	r.Timestamp = time.Now().Unix()
+	r.Nonce = newNonce()
+	r.Signature = s.sign(resultPayload(r))
+}
+
+// VerifyTask 校验任务签名、时间戳和 nonce
+func (s *Signer) VerifyTask(t *pb.CrawlerTask) error {
+	if t.Signature == "" || t.Nonce == "" || t.Timestamp == 0 {
+		return fmt.Errorf("%w: 缺少签名字段", ErrSignature)
+	}
+	expected := s.sign(taskPayload(t))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(t.Signature))) {
+		return fmt.Errorf("%w: 签名不匹配", ErrSignature)
+	}
+	now := time.Now()
+	signedAt := time.Unix(t.Timestamp, 0)
+	if now.Sub(signedAt) > s.window || signedAt.Sub(now) > s.window {
+		return fmt.Errorf("%w: 时间戳超出允许范围 %v", ErrSignature, s.window)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for nonce, seen := range s.nonces {
+		if now.Sub(seen) > 2*s.window {
+			delete(s.nonces, nonce)
+		}
+	}
+	if _, ok := s.nonces[t.Nonce]; ok {
+		return fmt.Errorf("%w: 重复的 nonce", ErrSignature)
+	}
+	s.nonces[t.Nonce] = now
+	return nil
+}
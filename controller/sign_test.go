@@ -0,0 +1,99 @@
+package controller
+
+import (
+	pb "agent/proto"
+	"errors"
+	"testing"
+	"time"
+)
+
+func signedTask(s *Signer, nonce string, at time.Time) *pb.CrawlerTask {
+	t := &pb.CrawlerTask{
+		Token:     "token",
+		Tag:       "tag",
+		Url:       "https://example.com",
+		Priority:  3,
+		TtlMs:     60000,
+		Timestamp: at.Unix(),
+		Nonce:     nonce,
+	}
+	t.Signature = s.sign(taskPayload(t))
+	return t
+}
+
+func TestVerifyTaskSignature(t *testing.T) {
+	s := NewSigner("secret", time.Minute)
+	if err := s.VerifyTask(signedTask(s, "n1", time.Now())); err != nil {
+		t.Fatalf("签名正确的任务应通过: %v", err)
+	}
+	other := NewSigner("other", time.Minute)
+	if err := s.VerifyTask(signedTask(other, "n2", time.Now())); !errors.Is(err, ErrSignature) {
+		t.Fatalf("密钥不同应拒绝, got %v", err)
+	}
+	// 签名覆盖的字段被修改后应拒绝
+	task := signedTask(s, "n3", time.Now())
+	task.TtlMs = 1
+	if err := s.VerifyTask(task); !errors.Is(err, ErrSignature) {
+		t.Fatalf("篡改 ttl_ms 后应拒绝, got %v", err)
+	}
+	if err := s.VerifyTask(&pb.CrawlerTask{Token: "token"}); !errors.Is(err, ErrSignature) {
+		t.Fatalf("缺少签名字段应拒绝, got %v", err)
+	}
+}
+
+func TestVerifyTaskTimestamp(t *testing.T) {
+	s := NewSigner("secret", time.Minute)
+	if err := s.VerifyTask(signedTask(s, "past", time.Now().Add(-2*time.Minute))); !errors.Is(err, ErrSignature) {
+		t.Fatalf("过早的时间戳应拒绝, got %v", err)
+	}
+	if err := s.VerifyTask(signedTask(s, "future", time.Now().Add(2*time.Minute))); !errors.Is(err, ErrSignature) {
+		t.Fatalf("超前的时间戳应拒绝, got %v", err)
+	}
+	if err := s.VerifyTask(signedTask(s, "skew", time.Now().Add(30*time.Second))); err != nil {
+		t.Fatalf("窗口内的偏差应接受: %v", err)
+	}
+}
+
+func TestVerifyTaskReplay(t *testing.T) {
+	s := NewSigner("secret", time.Minute)
+	task := signedTask(s, "once", time.Now())
+	if err := s.VerifyTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.VerifyTask(task); !errors.Is(err, ErrSignature) {
+		t.Fatalf("重复的 nonce 应拒绝, got %v", err)
+	}
+
+	// 超过两倍窗口的 nonce 被清理
+	s.nonces["old"] = time.Now().Add(-3 * time.Minute)
+	s.nonces["recent"] = time.Now().Add(-30 * time.Second)
+	if err := s.VerifyTask(signedTask(s, "fresh", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.nonces["old"]; ok {
+		t.Fatal("过期的 nonce 应被清理")
+	}
+	if _, ok := s.nonces["recent"]; !ok {
+		t.Fatal("窗口内的 nonce 应保留")
+	}
+}
+
+func TestSignResult(t *testing.T) {
+	s := NewSigner("secret", time.Minute)
+	r := &pb.CrawlerResult{Token: "token", Tag: "tag", Success: true, WebData: "ok",
+		Attempts: []*pb.CrawlAttempt{{ErrorClass: "timeout", Error: "i/o timeout", DurationMs: 10}}}
+	s.SignResult(r)
+	if r.Nonce == "" || r.Timestamp == 0 || r.Signature != s.sign(resultPayload(r)) {
+		t.Fatalf("结果签名不正确: %+v", r)
+	}
+	signature := r.Signature
+	r.Attempts[0].Error = "changed"
+	if s.sign(resultPayload(r)) == signature {
+		t.Fatal("尝试详情应参与签名")
+	}
+	r.Attempts[0].Error = "i/o timeout"
+	r.Coalesced = true
+	if s.sign(resultPayload(r)) == signature {
+		t.Fatal("coalesced 应参与签名")
+	}
+}
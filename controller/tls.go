@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions 与主控通信的 TLS 配置，gRPC 与 API 共用
+type TLSOptions struct {
+	Insecure   bool     // 明文通信，必须显式开启
+	CAFile     string   // 自定义 CA 证书，为空时使用系统根证书
+	CertFile   string   // 双向 TLS 的客户端证书
+	KeyFile    string   // 双向 TLS 的客户端私钥
+	ServerName string   // 覆盖证书校验使用的服务器名
+	PinSHA256  []string // 证书公钥 (SPKI) 的 SHA256 指纹，hex 或 base64，任一匹配即通过
+}
+
+// Mode 返回 TLS 配置的简要描述，用于日志
+func (o TLSOptions) Mode() string {
+	if o.Insecure {
+		return "insecure"
+	}
+	parts := []string{"tls"}
+	if o.CAFile != "" {
+		parts = append(parts, "custom-ca")
+	}
+	if o.CertFile != "" {
+		parts = append(parts, "mtls")
+	}
+	if len(o.PinSHA256) > 0 {
+		parts = append(parts, "pinned")
+	}
+	return strings.Join(parts, "+")
+}
+
+// Config 构造 tls.Config，Insecure 模式下返回 nil
+func (o TLSOptions) Config() (*tls.Config, error) {
+	if o.Insecure {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: o.ServerName,
+	}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书中没有有效的PEM证书: %s", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, fmt.Errorf("双向TLS需要同时提供客户端证书和私钥")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if len(o.PinSHA256) > 0 {
+		pins, err := parsePins(o.PinSHA256)
+		if err != nil {
+			return nil, err
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, cert := range cs.PeerCertificates {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				if pins[string(sum[:])] {
+					return nil
+				}
+			}
+			return fmt.Errorf("主控证书指纹与配置的 pin 不匹配")
+		}
+	}
+	return cfg, nil
+}
+
+// parsePins 解析 hex 或 base64 编码的 SHA256 指纹
+func parsePins(list []string) (map[string]bool, error) {
+	pins := make(map[string]bool, len(list))
+	for _, pin := range list {
+		pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+		raw, err := hex.DecodeString(strings.ReplaceAll(pin, ":", ""))
+		if err != nil {
+			raw, err = base64.StdEncoding.DecodeString(pin)
+		}
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("无效的证书指纹: %s", pin)
+		}
+		pins[string(raw)] = true
+	}
+	return pins, nil
+}
+
+// tokenCredentials 以 gRPC 元数据的形式在每次调用时携带 Token
+type tokenCredentials struct {
+	token      string
+	requireTLS bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.requireTLS
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePins(t *testing.T) {
+	sum := sha256.Sum256([]byte("spki"))
+	hexPin := hex.EncodeToString(sum[:])
+	var colonPin []string
+	for i := 0; i < len(hexPin); i += 2 {
+		colonPin = append(colonPin, strings.ToUpper(hexPin[i:i+2]))
+	}
+	for _, pin := range []string{
+		hexPin,
+		strings.Join(colonPin, ":"),
+		base64.StdEncoding.EncodeToString(sum[:]),
+		"sha256/" + base64.StdEncoding.EncodeToString(sum[:]),
+	} {
+		pins, err := parsePins([]string{pin})
+		if err != nil || !pins[string(sum[:])] {
+			t.Errorf("%s 解析失败: %v", pin, err)
+		}
+	}
+	for _, bad := range []string{hexPin[:10], base64.StdEncoding.EncodeToString(sum[:16]), "not-a-pin"} {
+		if _, err := parsePins([]string{bad}); err == nil {
+			t.Errorf("应拒绝 %q", bad)
+		}
+	}
+}
+
+func TestPinnedConnection(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0) // 指纹不匹配时服务端的握手错误
+	ts.StartTLS()
+	defer ts.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	spki := sha256.Sum256(ts.Certificate().RawSubjectPublicKeyInfo)
+	wrong := sha256.Sum256([]byte("other"))
+
+	dial := func(pin []byte) error {
+		cfg, err := TLSOptions{CAFile: caFile, PinSHA256: []string{hex.EncodeToString(pin)}}.Config()
+		if err != nil {
+			return err
+		}
+		conn, err := tls.Dial("tcp", ts.Listener.Addr().String(), cfg)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	if err := dial(spki[:]); err != nil {
+		t.Fatalf("指纹匹配时应连接成功: %v", err)
+	}
+	if err := dial(wrong[:]); err == nil || !strings.Contains(err.Error(), "pin") {
+		t.Fatalf("指纹不匹配时应拒绝连接, got %v", err)
+	}
+}
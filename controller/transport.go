@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"sync"
+	"time"
+)
+
+// TransportState 传输层状态
+type TransportState string
+
+const (
+	StateGRPC       TransportState = "grpc"       // 使用 gRPC
+	StateDegraded   TransportState = "degraded"   // gRPC 出现失败但未达到切换阈值，仍使用 gRPC
+	StateAPI        TransportState = "api"        // 使用 API，后台探测 gRPC 健康状态
+	StateRecovering TransportState = "recovering" // gRPC 探测成功但未达到回切阈值，仍使用 API
+)
+
+// maxTransitions 保留的状态切换记录条数
+const maxTransitions = 32
+
+// spiderService proto 中 SpiderService 的完整服务名，用于健康检查和 service config
+const spiderService = "spiders.SpiderService"
+
+// TransportConfig 模式切换的阈值和探测参数
+type TransportConfig struct {
+	FailThreshold    int           // gRPC 连续失败多少次后切到 API
+	RecoverThreshold int           // gRPC 连续探测成功多少次后切回
+	ProbeInterval    time.Duration // API 模式下探测 gRPC 的间隔
+	ProbeTimeout     time.Duration // 单次探测超时
+}
+
+// DefaultTransportConfig 默认的切换阈值
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		FailThreshold:    3,
+		RecoverThreshold: 3,
+		ProbeInterval:    15 * time.Second,
+		ProbeTimeout:     5 * time.Second,
+	}
+}
+
+// Transition 一次状态切换
+type Transition struct {
+	From   TransportState
+	To     TransportState
+	Reason string
+	At     time.Time
+}
+
+// TransportManager 在 gRPC 与 API 之间切换的状态机，gRPC 连接始终复用，
+// 切到 API 后通过 gRPC 健康检查协议探测，连续成功后才切回
+type TransportManager struct {
+	cfg   TransportConfig
+	probe func(ctx context.Context) error // 为 nil 时表示没有可用的 gRPC，始终使用 API
+
+	mu          sync.Mutex
+	state       TransportState
+	failures    int
+	successes   int
+	transitions []Transition
+}
+
+// NewTransportManager 创建状态机，probe 为 nil 时固定使用 API
+func NewTransportManager(cfg TransportConfig, probe func(ctx context.Context) error) *TransportManager {
+	if cfg.FailThreshold < 1 {
+		cfg.FailThreshold = 1
+	}
+	if cfg.RecoverThreshold < 1 {
+		cfg.RecoverThreshold = 1
+	}
+	m := &TransportManager{cfg: cfg, probe: probe, state: StateGRPC}
+	if probe == nil {
+		m.state = StateAPI
+	}
+	return m
+}
+
+// State 当前状态
+func (m *TransportManager) State() TransportState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// Mode 当前应使用的通信模式
+func (m *TransportManager) Mode() string {
+	switch m.State() {
+	case StateGRPC, StateDegraded:
+		return modeGRPC
+	default:
+		return modeAPI
+	}
+}
+
+// Report 记录一次请求结果，err 为 nil 表示成功；调用方只应上报连接类错误
+func (m *TransportManager) Report(mode string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mode == modeGRPC {
+		if m.state != StateGRPC && m.state != StateDegraded {
+			return
+		}
+		if err == nil {
+			m.failures = 0
+			if m.state == StateDegraded {
+				m.transition(StateGRPC, "gRPC请求恢复成功")
+			}
+			return
+		}
+		m.failures++
+		if m.failures >= m.cfg.FailThreshold {
+			m.transition(StateAPI, fmt.Sprintf("gRPC连续失败%d次: %v", m.failures, err))
+		} else if m.state == StateGRPC {
+			m.transition(StateDegraded, fmt.Sprintf("gRPC请求失败: %v", err))
+		}
+		return
+	}
+	// API 也失败且 gRPC 已探测到可用时，不再等待回切阈值
+	if err != nil && m.state == StateRecovering {
+		m.transition(StateGRPC, fmt.Sprintf("API请求失败且gRPC探测可用: %v", err))
+	}
+}
+
+// Run 在 API 模式下定期探测 gRPC，ctx 结束时返回
+func (m *TransportManager) Run(ctx context.Context) {
+	if m.probe == nil || m.cfg.ProbeInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(m.cfg.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Probe(ctx)
+		}
+	}
+}
+
+// Probe 探测一次 gRPC 健康状态，只在 API 模式下生效
+func (m *TransportManager) Probe(ctx context.Context) {
+	state := m.State()
+	if m.probe == nil || (state != StateAPI && state != StateRecovering) {
+		return
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, m.cfg.ProbeTimeout)
+	err := m.probe(probeCtx)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state != StateAPI && m.state != StateRecovering {
+		return
+	}
+	if err != nil {
+		m.successes = 0
+		if m.state == StateRecovering {
+			m.transition(StateAPI, fmt.Sprintf("gRPC健康检查失败: %v", err))
+		}
+		return
+	}
+	m.successes++
+	if m.successes >= m.cfg.RecoverThreshold {
+		m.transition(StateGRPC, fmt.Sprintf("gRPC健康检查连续成功%d次", m.successes))
+	} else if m.state == StateAPI {
+		m.transition(StateRecovering, "gRPC健康检查成功")
+	}
+}
+
+// Transitions 返回最近的状态切换记录
+func (m *TransportManager) Transitions() []Transition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Transition(nil), m.transitions...)
+}
+
+// transition 切换状态并记录，调用方需持有锁
+func (m *TransportManager) transition(to TransportState, reason string) {
+	from := m.state
+	m.state = to
+	switch to {
+	case StateGRPC, StateAPI:
+		m.failures = 0
+		m.successes = 0
+	}
+	m.transitions = append(m.transitions, Transition{From: from, To: to, Reason: reason, At: time.Now()})
+	if len(m.transitions) > maxTransitions {
+		m.transitions = m.transitions[len(m.transitions)-maxTransitions:]
+	}
+	transportSwitches.WithLabelValues(string(from), string(to)).Inc()
+	logger.Info("通信状态切换", "from", from, "to", to, "reason", reason)
+}
+
+// grpcHealthProbe 使用 gRPC 健康检查协议探测主控；主控未实现健康检查服务时，
+// 能返回 Unimplemented 说明连接可用，同样视为健康。探测复用已有连接
+func grpcHealthProbe(conn *grpc.ClientConn) func(ctx context.Context) error {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		conn.Connect()
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: spiderService})
+		if status.Code(err) == codes.NotFound {
+			// 主控只注册了整体状态
+			resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+		}
+		if status.Code(err) == codes.Unimplemented {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("主控状态: %s", resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,219 @@
+package controller
+
+import (
+	pb "agent/proto"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeSpiderServer 可切换可用状态的 gRPC 主控
+type fakeSpiderServer struct {
+	pb.UnimplementedSpiderServiceServer
+	down atomic.Bool
+}
+
+func (s *fakeSpiderServer) GetTask(ctx context.Context, req *pb.TaskRequest) (*pb.CrawlerTask, error) {
+	if s.down.Load() {
+		return nil, status.Error(codes.Unavailable, "controller down")
+	}
+	return &pb.CrawlerTask{Tag: "grpc"}, nil
+}
+
+// startBufconn 启动基于 bufconn 的 gRPC 主控，withHealth 为 false 时不注册健康检查服务
+func startBufconn(t *testing.T, withHealth bool) (*bufconn.Listener, *fakeSpiderServer, *health.Server) {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	spider := &fakeSpiderServer{}
+	pb.RegisterSpiderServiceServer(srv, spider)
+	var hs *health.Server
+	if withHealth {
+		hs = health.NewServer()
+		healthpb.RegisterHealthServer(srv, hs)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis, spider, hs
+}
+
+func bufDialer(lis *bufconn.Listener) func(context.Context, string) (net.Conn, error) {
+	return func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}
+}
+
+func TestTransportHysteresis(t *testing.T) {
+	var healthy atomic.Bool
+	m := NewTransportManager(TransportConfig{FailThreshold: 3, RecoverThreshold: 2, ProbeTimeout: time.Second},
+		func(ctx context.Context) error {
+			if !healthy.Load() {
+				return errors.New("down")
+			}
+			return nil
+		})
+	fail := errors.New("unavailable")
+
+	m.Report(modeGRPC, fail)
+	if m.State() != StateDegraded || m.Mode() != modeGRPC {
+		t.Fatalf("一次失败后应为 degraded 且继续使用 gRPC, got %s", m.State())
+	}
+	m.Report(modeGRPC, nil)
+	if m.State() != StateGRPC {
+		t.Fatalf("成功后应恢复 grpc, got %s", m.State())
+	}
+	for i := 0; i < 3; i++ {
+		m.Report(modeGRPC, fail)
+	}
+	if m.State() != StateAPI || m.Mode() != modeAPI {
+		t.Fatalf("连续失败达到阈值后应切到 api, got %s", m.State())
+	}
+
+	m.Probe(context.Background())
+	if m.State() != StateAPI {
+		t.Fatalf("探测失败应保持 api, got %s", m.State())
+	}
+	healthy.Store(true)
+	m.Probe(context.Background())
+	if m.State() != StateRecovering || m.Mode() != modeAPI {
+		t.Fatalf("一次探测成功应为 recovering 且继续使用 API, got %s", m.State())
+	}
+	m.Probe(context.Background())
+	if m.State() != StateGRPC {
+		t.Fatalf("连续探测成功达到阈值后应切回 grpc, got %s", m.State())
+	}
+
+	want := []TransportState{StateDegraded, StateGRPC, StateDegraded, StateAPI, StateRecovering, StateGRPC}
+	got := m.Transitions()
+	if len(got) != len(want) {
+		t.Fatalf("切换记录数量 %d, 期望 %d", len(got), len(want))
+	}
+	for i, tr := range got {
+		if tr.To != want[i] {
+			t.Errorf("第 %d 次切换到 %s, 期望 %s", i, tr.To, want[i])
+		}
+	}
+}
+
+func TestTransportAPIFailureWhileRecovering(t *testing.T) {
+	m := NewTransportManager(TransportConfig{FailThreshold: 1, RecoverThreshold: 5, ProbeTimeout: time.Second},
+		func(ctx context.Context) error { return nil })
+	m.Report(modeGRPC, errors.New("unavailable"))
+	m.Probe(context.Background())
+	if m.State() != StateRecovering {
+		t.Fatalf("got %s", m.State())
+	}
+	m.Report(modeAPI, errors.New("502"))
+	if m.State() != StateGRPC {
+		t.Fatalf("API 失败且 gRPC 可用时应直接切回, got %s", m.State())
+	}
+}
+
+func TestGRPCHealthProbe(t *testing.T) {
+	lis, _, hs := startBufconn(t, true)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(bufDialer(lis)), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	probe := grpcHealthProbe(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hs.SetServingStatus(spiderService, healthpb.HealthCheckResponse_NOT_SERVING)
+	if err := probe(ctx); err == nil {
+		t.Fatal("NOT_SERVING 应视为不健康")
+	}
+	hs.SetServingStatus(spiderService, healthpb.HealthCheckResponse_SERVING)
+	if err := probe(ctx); err != nil {
+		t.Fatalf("SERVING 应视为健康: %v", err)
+	}
+
+	// 未实现健康检查服务的主控
+	lis2, _, _ := startBufconn(t, false)
+	conn2, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(bufDialer(lis2)), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+	if err := grpcHealthProbe(conn2)(ctx); err != nil {
+		t.Fatalf("Unimplemented 应视为连接可用: %v", err)
+	}
+}
+
+func TestControllerFailoverAndRecovery(t *testing.T) {
+	lis, spider, hs := startBufconn(t, true)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"tag":"api","url":"https://example.com"}}`))
+	}))
+	defer ts.Close()
+
+	c, err := New(Options{
+		Token:       "token",
+		Hosts:       []string{"127.0.0.1"},
+		GrpcPort:    "50051",
+		TLS:         TLSOptions{Insecure: true},
+		APIBaseURLs: []string{ts.URL},
+		Transport:   TransportConfig{FailThreshold: 2, RecoverThreshold: 2, ProbeTimeout: time.Second},
+		GRPC:        GRPCOptions{MaxSendMsgSize: 1 << 20, MaxRecvMsgSize: 1 << 20},
+		dialer:      bufDialer(lis),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conn := c.grpcConn
+	ctx := context.Background()
+
+	spider.down.Store(true)
+	hs.SetServingStatus(spiderService, healthpb.HealthCheckResponse_NOT_SERVING)
+	for i := 0; i < 2; i++ {
+		_, err := c.GetTaskGRPC(ctx)
+		c.ReportResult(modeGRPC, err)
+	}
+	if c.GetMode() != modeAPI {
+		t.Fatalf("gRPC 连续失败后应切到 API, got %s", c.Transport().State())
+	}
+	task, err := c.GetTaskAPI(ctx)
+	if err != nil || task.Tag != "api" {
+		t.Fatalf("API 取任务失败: %v", err)
+	}
+
+	c.Transport().Probe(ctx)
+	if c.GetMode() != modeAPI {
+		t.Fatal("主控不健康时不应切回 gRPC")
+	}
+	spider.down.Store(false)
+	hs.SetServingStatus(spiderService, healthpb.HealthCheckResponse_SERVING)
+	c.Transport().Probe(ctx)
+	if c.GetMode() != modeAPI {
+		t.Fatal("未达到回切阈值时应继续使用 API")
+	}
+	c.Transport().Probe(ctx)
+	if c.GetMode() != modeGRPC {
+		t.Fatalf("连续探测成功后应切回 gRPC, got %s", c.Transport().State())
+	}
+	task, err = c.GetTaskGRPC(ctx)
+	if err != nil || task.Tag != "grpc" {
+		t.Fatalf("gRPC 取任务失败: %v", err)
+	}
+	if c.grpcConn != conn {
+		t.Fatal("切换过程中不应重建 gRPC 连接")
+	}
+}
@@ -1,35 +1,129 @@
 package crawler
 
 import (
+	"agent/logging"
+	"agent/tracing"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/imroc/req/v3"
-	"log"
 	"net/url"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var logger = logging.For("crawler")
+
 // Crawler 页面爬取客户端
 type Crawler struct {
-	httpClient   *req.Client
-	cacheMutex   sync.RWMutex
-	cacheExpiry  time.Duration
-	userAgent    string
+	httpClient  *req.Client
+	cacheMutex  sync.RWMutex
+	cacheExpiry time.Duration
+	robotsCache map[string]robotsEntry // 受 cacheMutex 保护
+	userAgent   string
+	retryMutex  sync.RWMutex
+	retryPolicy RetryPolicy
+	clientMutex sync.RWMutex // 保护 httpClient、userAgent、options 和 politeness
+	options     Options
+	politeness  *politeness
+	proxyIndex  atomic.Uint64
+}
+
+// Attempt 单次请求的结果
+type Attempt struct {
+	Error      string
+	ErrorClass string
+	StatusCode int
+	Duration   time.Duration
+	Throttle   time.Duration // 请求前等待域名限制的时间，不计入 Duration
+
+	retryAfter time.Duration // 响应要求的 Retry-After
+}
+
+// FetchResult 爬取结果，包含每次尝试的详情
+type FetchResult struct {
+	WebData    string
+	Success    bool
+	ErrorClass string // 最后一次失败的错误分类，成功时为空
+	Attempts   []Attempt
+	Throttle   time.Duration // 各次请求等待域名限制的总时间
 }
 
 // NewCrawler 创建新的爬虫客户端
 func NewCrawler() *Crawler {
 	crawler := &Crawler{
-		cacheExpiry:  2 * time.Hour,
-		httpClient:   req.C(),
+		cacheExpiry: 2 * time.Hour,
+		robotsCache: map[string]robotsEntry{},
+		retryPolicy: DefaultRetryPolicy(),
 	}
-	crawler.httpClient.SetTimeout(10 * time.Second)
-	crawler.httpClient.ImpersonateChrome()
-	crawler.userAgent = crawler.httpClient.Headers.Get("User-Agent")
+	crawler.SetOptions(DefaultOptions())
 	return crawler
 }
 
+// SetOptions 按新的参数重建 HTTP 客户端，进行中的请求继续使用旧客户端
+func (c *Crawler) SetOptions(opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	client := req.C().SetTimeout(opts.Timeout)
+	switch opts.Profile {
+	case ProfileChrome:
+		client.ImpersonateChrome()
+	case ProfileFirefox:
+		client.ImpersonateFirefox()
+	case ProfileSafari:
+		client.ImpersonateSafari()
+	}
+	c.clientMutex.Lock()
+	defer c.clientMutex.Unlock()
+	c.httpClient = client
+	c.userAgent = client.Headers.Get("User-Agent")
+	// 域名限制未变化时保留各域名的状态
+	if c.politeness == nil || !slices.Equal(c.options.HostLimits, opts.HostLimits) || c.options.MaxRetryAfter != opts.MaxRetryAfter {
+		c.politeness = newPoliteness(opts.HostLimits, opts.MaxRetryAfter)
+	}
+	c.options = opts
+	return nil
+}
+
+// Options 获取当前的爬虫参数
+func (c *Crawler) Options() Options {
+	c.clientMutex.RLock()
+	defer c.clientMutex.RUnlock()
+	return c.options
+}
+
+// newClient 为一次任务复制 HTTP 客户端，配置了多个代理时轮流使用；同时返回当前的域名限制
+func (c *Crawler) newClient() (*req.Client, *politeness) {
+	c.clientMutex.RLock()
+	client := c.httpClient.Clone()
+	proxies := c.options.Proxies
+	pol := c.politeness
+	c.clientMutex.RUnlock()
+	if len(proxies) > 0 {
+		proxy := proxies[int(c.proxyIndex.Add(1)-1)%len(proxies)]
+		client.SetProxyURL(proxy)
+	}
+	return client, pol
+}
+
+// SetRetryPolicy 设置默认重试策略
+func (c *Crawler) SetRetryPolicy(policy RetryPolicy) {
+	c.retryMutex.Lock()
+	defer c.retryMutex.Unlock()
+	c.retryPolicy = policy
+}
+
+// RetryPolicy 获取默认重试策略
+func (c *Crawler) RetryPolicy() RetryPolicy {
+	c.retryMutex.RLock()
+	defer c.retryMutex.RUnlock()
+	return c.retryPolicy
+}
+
 // getCacheKey 获取缓存键
 func (c *Crawler) getCacheKey(domain, userAgent string) string {
 	return fmt.Sprintf("%s:%s", domain, userAgent)
@@ -68,26 +162,135 @@ func (c *Crawler) isCloudFlareChallenge(resp *req.Response) bool {
 		strings.Contains(body, "Wait a moment")
 }
 
-// FetchWebData 获取网页数据
-func (c *Crawler) FetchWebData(url string) (string, bool) {
-	client := c.httpClient.Clone()
+// FetchWebData 按重试策略获取网页数据，ctx 结束时中断请求；
+// billingType 需要遵守 robots.txt 时，被禁止的页面不发起请求，返回 robots_disallowed
+func (c *Crawler) FetchWebData(ctx context.Context, url string, policy RetryPolicy, billingType string) *FetchResult {
+	client, pol := c.newClient()
+	host := hostOf(url)
+	result := &FetchResult{}
+	var crawlDelay time.Duration
+	if c.RespectsRobots(billingType) {
+		var a Attempt
+		a, crawlDelay = c.robotsAttempt(ctx, client, pol, url)
+		result.Throttle += a.Throttle
+		if a.ErrorClass != "" {
+			logger.InfoContext(ctx, "未爬取页面", logging.KeyErrorClass, a.ErrorClass, logging.KeyError, a.Error)
+			result.Attempts = append(result.Attempts, a)
+			result.ErrorClass = a.ErrorClass
+			return result
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		attemptCtx, span := tracing.Start(ctx, "CrawlAttempt", tracing.KindClient, "attempt", attempt, "url.full", url)
+		data, a := c.politeFetch(attemptCtx, client, pol, host, url, crawlDelay)
+		result.Throttle += a.Throttle
+		if paused := pol.pause(host, a.retryAfter); paused > 0 {
+			logger.InfoContext(ctx, "按 Retry-After 暂停访问该域名", "host", host, "pause", paused)
+		}
+		span.SetAttributes("http.status_code", a.StatusCode, "error_class", a.ErrorClass, "throttle_ms", a.Throttle)
+		if a.ErrorClass != "" {
+			span.RecordError(errors.New(a.Error))
+		}
+		span.End()
+		result.Attempts = append(result.Attempts, a)
+		if a.ErrorClass == "" {
+			result.WebData = data
+			result.Success = true
+			result.ErrorClass = ""
+			logger.InfoContext(ctx, "获取页面成功", logging.KeyAttempt, attempt, logging.KeyDuration, a.Duration)
+			return result
+		}
+		logger.WarnContext(ctx, "获取页面失败", logging.KeyAttempt, attempt, logging.KeyDuration, a.Duration,
+			logging.KeyErrorClass, a.ErrorClass, "status", a.StatusCode, logging.KeyError, a.Error)
+		result.ErrorClass = a.ErrorClass
+		if attempt >= policy.MaxAttempts || !policy.Retryable(a.ErrorClass) || ctx.Err() != nil {
+			return result
+		}
+		backoff := policy.Backoff(attempt)
+		logger.DebugContext(ctx, "等待后重试", logging.KeyAttempt, attempt, logging.KeyErrorClass, a.ErrorClass, "backoff", backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result
+		case <-timer.C:
+		}
+	}
+}
+
+// addTraceEvents 把一次请求各阶段的耗时记录为 span 事件，复用连接时没有 DNS、连接和 TLS 阶段
+func addTraceEvents(span *tracing.Span, start time.Time, ti req.TraceInfo) {
+	span.SetAttributes("net.conn_reused", ti.IsConnReused)
+	if ti.RemoteAddr != nil {
+		span.SetAttributes("net.peer.addr", ti.RemoteAddr.String())
+	}
+	if !ti.IsConnReused {
+		dnsDone := start.Add(ti.DNSLookupTime)
+		if ti.DNSLookupTime > 0 {
+			span.AddEventAt("dns", dnsDone, "duration_ms", ti.DNSLookupTime)
+		}
+		if ti.TCPConnectTime > 0 {
+			span.AddEventAt("connect", dnsDone.Add(ti.TCPConnectTime), "duration_ms", ti.TCPConnectTime)
+		}
+		if ti.TLSHandshakeTime > 0 {
+			span.AddEventAt("tls", start.Add(ti.ConnectTime), "duration_ms", ti.TLSHandshakeTime)
+		}
+	}
+	if ti.FirstResponseTime > 0 {
+		span.AddEventAt("ttfb", start.Add(ti.ConnectTime+ti.FirstResponseTime), "duration_ms", ti.FirstResponseTime)
+	}
+}
+
+// politeFetch 等待域名的并发名额、令牌和 Crawl-delay 后发起一次请求，等待期间任务到期时返回 throttled
+func (c *Crawler) politeFetch(ctx context.Context, client *req.Client, pol *politeness, host, url string, crawlDelay time.Duration) (string, Attempt) {
+	release, waited, err := pol.wait(ctx, host, crawlDelay)
+	if err != nil {
+		a := Attempt{Error: err.Error(), ErrorClass: ErrorClassThrottled, Throttle: waited}
+		if errors.Is(err, context.Canceled) {
+			a.ErrorClass = ErrorClassCanceled
+		}
+		return "", a
+	}
+	defer release()
+	if waited > 0 {
+		tracing.SpanFromContext(ctx).AddEvent("throttled", "duration_ms", waited)
+	}
+	data, a := c.fetchOnce(ctx, client, url)
+	a.Throttle = waited
+	return data, a
+}
+
+// fetchOnce 发起一次请求，成功时 Attempt.ErrorClass 为空
+func (c *Crawler) fetchOnce(ctx context.Context, client *req.Client, url string) (string, Attempt) {
 	startTime := time.Now()
-	// 第一次请求
-	resp, err := client.R().Get(url)
+	r := client.R().SetContext(ctx)
+	span := tracing.SpanFromContext(ctx)
+	if span != nil {
+		r.EnableTrace()
+	}
+	resp, err := r.Get(url)
+	attempt := Attempt{Duration: time.Since(startTime)}
+	if span != nil && resp != nil && resp.Response != nil {
+		addTraceEvents(span, startTime, resp.TraceInfo())
+	}
 	// 先检查错误，再检查响应
 	if err != nil {
-		log.Printf("获取页面失败: %v, URL: %s", err, url)
-		return "", false
+		attempt.Error = err.Error()
+		attempt.ErrorClass = classifyError(err)
+		return "", attempt
 	}
+	attempt.StatusCode = resp.StatusCode
+	attempt.retryAfter = retryAfter(resp.StatusCode, resp.Header)
 	// 检查是否需要处理cf5s验证
 	if c.isCloudFlareChallenge(resp) {
-		log.Printf("检测到 CloudFlare 验证, URL: %s", url)
-		return "", false
+		attempt.Error = "CloudFlare challenge"
+		attempt.ErrorClass = ErrorClassCloudFlare
+		return "", attempt
 	}
 	if !resp.IsSuccessState() {
-		log.Printf("请求失败，状态码: %d, URL: %s", resp.StatusCode, url)
-		return "", false
+		attempt.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		attempt.ErrorClass = classifyStatus(resp.StatusCode)
+		return "", attempt
 	}
-	log.Printf("获取页面成功 - URL: %s, 耗时: %v", url, time.Since(startTime))
-	return resp.String(), true
+	return resp.String(), attempt
 }
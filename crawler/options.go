@@ -0,0 +1,62 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// 浏览器指纹模板
+const (
+	ProfileChrome  = "chrome"
+	ProfileFirefox = "firefox"
+	ProfileSafari  = "safari"
+	ProfileNone    = "none" // 不模拟浏览器，使用 req 默认的 TLS 指纹和请求头
+)
+
+// Options 爬虫 HTTP 客户端参数
+type Options struct {
+	Timeout time.Duration // 单次请求超时
+	Profile string        // 模拟的浏览器指纹
+	Proxies []string      // 代理地址，多个时按任务轮流使用，为空时直连
+
+	HostLimits    []HostLimit   // 按域名限制请求频率和并发
+	MaxRetryAfter time.Duration // 遵守 429/503 响应的 Retry-After 时最多暂停的时间，0 表示不遵守
+
+	RobotsBillingTypes []string // 需要遵守 robots.txt 的计费类型，* 表示全部，为空时都不遵守
+}
+
+// DefaultOptions 默认的爬虫参数
+func DefaultOptions() Options {
+	return Options{
+		Timeout: 10 * time.Second,
+		Profile: ProfileChrome,
+	}
+}
+
+// Validate 检查参数是否有效
+func (o Options) Validate() error {
+	if o.Timeout <= 0 {
+		return fmt.Errorf("爬取超时必须大于0")
+	}
+	switch o.Profile {
+	case ProfileChrome, ProfileFirefox, ProfileSafari, ProfileNone:
+	default:
+		return fmt.Errorf("无效的浏览器指纹: %s (可选: chrome, firefox, safari, none)", o.Profile)
+	}
+	if o.MaxRetryAfter < 0 {
+		return fmt.Errorf("Retry-After 暂停上限不能为负")
+	}
+	for _, proxy := range o.Proxies {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("无效的代理地址: %s", proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("代理协议不支持: %s (可选: http, https, socks5)", proxy)
+		}
+	}
+	return nil
+}
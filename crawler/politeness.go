@@ -0,0 +1,306 @@
+package crawler
+
+import (
+	"agent/limiter"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimit 一类域名的访问限制，0 表示不限制
+type HostLimit struct {
+	Pattern       string  // 域名、*.example.com（含 example.com 本身及其子域名）或 *
+	Rate          float64 // 每秒请求数
+	Burst         int     // 令牌桶容量，Rate 大于 0 时至少为 1
+	MaxConcurrent int     // 同时进行的请求数
+}
+
+func (l HostLimit) String() string {
+	return fmt.Sprintf("%s=%s/%d/%d", l.Pattern, strconv.FormatFloat(l.Rate, 'f', -1, 64), l.Burst, l.MaxConcurrent)
+}
+
+// ParseHostLimits 解析域名限制，每项格式为 pattern=每秒请求数/突发数/并发数，如 *.example.com=0.5/1/1
+func ParseHostLimits(items []string) ([]HostLimit, error) {
+	limits := make([]HostLimit, 0, len(items))
+	seen := map[string]bool{}
+	for _, item := range items {
+		pattern, spec, ok := strings.Cut(item, "=")
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		parts := strings.Split(spec, "/")
+		if !ok || pattern == "" || len(parts) != 3 {
+			return nil, fmt.Errorf("无效的域名限制: %s (格式: *.example.com=每秒请求数/突发数/并发数)", item)
+		}
+		if strings.Contains(pattern[1:], "*") || (strings.HasPrefix(pattern, "*") && pattern != "*" && !strings.HasPrefix(pattern, "*.")) {
+			return nil, fmt.Errorf("无效的域名模式: %s (可选: example.com, *.example.com, *)", pattern)
+		}
+		if seen[pattern] {
+			return nil, fmt.Errorf("域名模式重复: %s", pattern)
+		}
+		seen[pattern] = true
+		limit := HostLimit{Pattern: pattern}
+		var err1, err2, err3 error
+		limit.Rate, err1 = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		limit.Burst, err2 = strconv.Atoi(strings.TrimSpace(parts[1]))
+		limit.MaxConcurrent, err3 = strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err1 != nil || err2 != nil || err3 != nil || limit.Rate < 0 || limit.Burst < 0 || limit.MaxConcurrent < 0 {
+			return nil, fmt.Errorf("无效的域名限制: %s (数值不能为负)", item)
+		}
+		if limit.Rate > 0 && limit.Burst < 1 {
+			limit.Burst = 1
+		}
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
+
+// matchHostLimit 域名对应的限制：完全匹配优先，其次是最长的 *. 后缀，最后是 *
+func matchHostLimit(limits []HostLimit, host string) (HostLimit, bool) {
+	best, bestLen := HostLimit{}, -1
+	for _, l := range limits {
+		n := -1
+		switch {
+		case l.Pattern == host:
+			return l, true
+		case l.Pattern == "*":
+			n = 0
+		case strings.HasPrefix(l.Pattern, "*."):
+			suffix := l.Pattern[1:]
+			if strings.HasSuffix(host, suffix) || host == suffix[1:] {
+				n = len(suffix)
+			}
+		}
+		if n > bestLen {
+			best, bestLen = l, n
+		}
+	}
+	return best, bestLen >= 0
+}
+
+// hostState 单个域名的令牌桶、并发名额、Crawl-delay 间隔和 Retry-After 暂停
+type hostState struct {
+	limit HostLimit
+	slots *limiter.Limiter // MaxConcurrent 为 0 时为空
+
+	mu           sync.Mutex
+	tokens       float64 // 可为负，表示已预约的令牌
+	updated      time.Time
+	blockedUntil time.Time
+	nextAt       time.Time // 按 Crawl-delay 下一次请求最早的时间
+}
+
+// reserve 预约一个令牌，interval 大于 0 时与上一次请求至少间隔 interval，返回需要等待的时间
+func (h *hostState) reserve(now time.Time, interval time.Duration) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var wait time.Duration
+	if h.limit.Rate > 0 {
+		h.tokens = min(float64(h.limit.Burst), h.tokens+now.Sub(h.updated).Seconds()*h.limit.Rate)
+		h.updated = now
+		h.tokens--
+		if h.tokens < 0 {
+			wait = time.Duration(-h.tokens / h.limit.Rate * float64(time.Second))
+		}
+	}
+	if blocked := h.blockedUntil.Sub(now); blocked > wait {
+		wait = blocked
+	}
+	if interval > 0 {
+		if next := h.nextAt.Sub(now); next > wait {
+			wait = next
+		}
+		h.nextAt = now.Add(wait + interval)
+	}
+	return wait
+}
+
+// cancel 归还未使用的令牌
+func (h *hostState) cancel() {
+	if h.limit.Rate <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens = min(float64(h.limit.Burst), h.tokens+1)
+}
+
+// block 在 until 之前暂停访问该域名
+func (h *hostState) block(until time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if until.After(h.blockedUntil) {
+		h.blockedUntil = until
+	}
+}
+
+// idle 没有在途请求、令牌已满且未被暂停，可以回收
+func (h *hostState) idle(now time.Time) bool {
+	if h.slots != nil && h.slots.InUse() > 0 {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	full := h.limit.Rate <= 0 || h.tokens+now.Sub(h.updated).Seconds()*h.limit.Rate >= float64(h.limit.Burst)
+	return full && !now.Before(h.blockedUntil) && !now.Before(h.nextAt)
+}
+
+// Yielder 任务占用的全局并发名额，等待域名限制期间让出，等待结束后重新占用
+type Yielder interface {
+	Yield()
+	Resume(ctx context.Context) error
+}
+
+type yielderKey struct{}
+
+// WithYielder 在 ctx 中附加任务的全局名额，爬取等待域名限制时让给其他域名的任务
+func WithYielder(ctx context.Context, y Yielder) context.Context {
+	return context.WithValue(ctx, yielderKey{}, y)
+}
+
+// YielderFromContext ctx 中任务的全局名额，没有时返回 nil
+func YielderFromContext(ctx context.Context) Yielder {
+	y, _ := ctx.Value(yielderKey{}).(Yielder)
+	return y
+}
+
+// maxHostStates 超过该数量时回收空闲的域名状态
+const maxHostStates = 1024
+
+// politeness 按域名限制请求频率和并发
+type politeness struct {
+	limits        []HostLimit
+	maxRetryAfter time.Duration // 0 表示不处理 Retry-After
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+func newPoliteness(limits []HostLimit, maxRetryAfter time.Duration) *politeness {
+	return &politeness{limits: limits, maxRetryAfter: maxRetryAfter, hosts: map[string]*hostState{}}
+}
+
+// state 域名的状态，没有匹配的限制、不处理 Retry-After 且不需要 Crawl-delay 时返回 nil
+func (p *politeness) state(host string, interval time.Duration) *hostState {
+	limit, ok := matchHostLimit(p.limits, host)
+	if !ok && p.maxRetryAfter <= 0 && interval <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h, ok := p.hosts[host]; ok {
+		return h
+	}
+	if len(p.hosts) >= maxHostStates {
+		now := time.Now()
+		for name, h := range p.hosts {
+			if h.idle(now) {
+				delete(p.hosts, name)
+			}
+		}
+	}
+	h := &hostState{limit: limit, tokens: float64(limit.Burst), updated: time.Now()}
+	if limit.MaxConcurrent > 0 {
+		h.slots = limiter.New(limit.MaxConcurrent)
+	}
+	p.hosts[host] = h
+	return h
+}
+
+// wait 等待域名的并发名额和令牌，interval 为 robots.txt 的 Crawl-delay；需要等待时让出 ctx 中的全局名额，
+// 等待结束后重新占用。返回释放域名名额的函数和等待的时间，ctx 结束时返回其错误，此时全局名额可能已让出
+func (p *politeness) wait(ctx context.Context, host string, interval time.Duration) (release func(), waited time.Duration, err error) {
+	h := p.state(host, interval)
+	if h == nil {
+		return func() {}, 0, nil
+	}
+	start := time.Now()
+	y := YielderFromContext(ctx)
+	yielded := false
+	yield := func() {
+		if y != nil && !yielded {
+			y.Yield()
+			yielded = true
+		}
+	}
+	release = func() {}
+	if h.slots != nil {
+		if !h.slots.TryAcquire() {
+			yield()
+			if err := h.slots.Acquire(ctx); err != nil {
+				return nil, time.Since(start), err
+			}
+		}
+		release = h.slots.Release
+	}
+	if delay := h.reserve(time.Now(), interval); delay > 0 {
+		yield()
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			h.cancel()
+			release()
+			return nil, time.Since(start), ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if yielded {
+		if err := y.Resume(ctx); err != nil {
+			release()
+			return nil, time.Since(start), err
+		}
+	}
+	return release, time.Since(start), nil
+}
+
+// pause 在 d 之后才继续访问该域名，d 不超过 maxRetryAfter；返回实际暂停的时长
+func (p *politeness) pause(host string, d time.Duration) time.Duration {
+	if p.maxRetryAfter <= 0 || d <= 0 {
+		return 0
+	}
+	d = min(d, p.maxRetryAfter)
+	if h := p.state(host, 0); h != nil {
+		h.block(time.Now().Add(d))
+	}
+	return d
+}
+
+// retryAfter 429/503 响应中 Retry-After 要求等待的时间
+func retryAfter(statusCode int, header http.Header) time.Duration {
+	if statusCode != http.StatusTooManyRequests && statusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	d, ok := parseRetryAfter(header.Get("Retry-After"), time.Now())
+	if !ok {
+		return 0
+	}
+	return d
+}
+
+// parseRetryAfter 解析秒数或 HTTP 日期格式的 Retry-After
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second, secs >= 0
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+// hostOf URL 中小写的主机名，不含端口
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
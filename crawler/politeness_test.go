@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHostLimitMatching(t *testing.T) {
+	limits, err := ParseHostLimits([]string{"*=2/4/2", "*.example.com=0.5/0/1", "api.example.com=0/0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for host, want := range map[string]string{
+		"api.example.com": "api.example.com",
+		"www.example.com": "*.example.com",
+		"example.com":     "*.example.com",
+		"notexample.com":  "*",
+	} {
+		if got, _ := matchHostLimit(limits, host); got.Pattern != want {
+			t.Errorf("%s 匹配到 %s, 期望 %s", host, got.Pattern, want)
+		}
+	}
+	if limits[1].Burst != 1 {
+		t.Errorf("限速时突发数至少为 1, got %d", limits[1].Burst)
+	}
+	for _, bad := range []string{"example.com=1/1", "a*.com=1/1/1", "*=-1/1/1"} {
+		if _, err := ParseHostLimits([]string{bad}); err == nil {
+			t.Errorf("应拒绝 %q", bad)
+		}
+	}
+}
+
+func TestPolitenessWaitAndRetryAfter(t *testing.T) {
+	p := newPoliteness([]HostLimit{{Pattern: "*", Rate: 20, Burst: 1, MaxConcurrent: 1}}, time.Second)
+	ctx := context.Background()
+	release, waited, err := p.wait(ctx, "a.test", 0)
+	if err != nil || waited > 10*time.Millisecond {
+		t.Fatalf("第一个请求不应等待: %v %v", waited, err)
+	}
+	// 并发名额被占用时等待到任务到期
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := p.wait(short, "a.test", 0); err == nil {
+		t.Fatal("并发名额已满时应等待")
+	}
+	release()
+	// 令牌按每秒 20 个补充，下一个请求约等待 50ms
+	release, waited, err = p.wait(ctx, "a.test", 0)
+	if err != nil || waited < 30*time.Millisecond {
+		t.Fatalf("应等待令牌: %v %v", waited, err)
+	}
+	release()
+
+	header := http.Header{"Retry-After": []string{"120"}}
+	if d := p.pause("b.test", retryAfter(http.StatusTooManyRequests, header)); d != time.Second {
+		t.Fatalf("Retry-After 应限制在上限内, got %v", d)
+	}
+	if d := retryAfter(http.StatusOK, header); d != 0 {
+		t.Fatalf("只处理 429/503, got %v", d)
+	}
+	release, waited, err = p.wait(ctx, "b.test", 0)
+	if err != nil || waited < 900*time.Millisecond {
+		t.Fatalf("应等待 Retry-After: %v %v", waited, err)
+	}
+	release()
+}
+
+type countingYielder struct{ yields, resumes int }
+
+func (y *countingYielder) Yield()                       { y.yields++ }
+func (y *countingYielder) Resume(context.Context) error { y.resumes++; return nil }
+
+func TestPolitenessYieldsWhileWaiting(t *testing.T) {
+	p := newPoliteness([]HostLimit{{Pattern: "*", Rate: 20, Burst: 1}}, 0)
+	y := &countingYielder{}
+	ctx := WithYielder(context.Background(), y)
+	release, _, err := p.wait(ctx, "a.test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if y.yields != 0 {
+		t.Fatalf("不需要等待时不应让出名额, yields=%d", y.yields)
+	}
+	release, _, err = p.wait(ctx, "a.test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if y.yields != 1 || y.resumes != 1 {
+		t.Fatalf("等待令牌时应让出并重新占用名额, yields=%d resumes=%d", y.yields, y.resumes)
+	}
+}
@@ -0,0 +1,143 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 爬取失败的错误分类
+const (
+	ErrorClassTimeout           = "timeout"            // 连接或读取超时
+	ErrorClassConnReset         = "conn_reset"         // 连接被重置或提前关闭
+	ErrorClassConnRefused       = "conn_refused"       // 连接被拒绝
+	ErrorClassDNS               = "dns"                // 域名解析失败
+	ErrorClassTLS               = "tls"                // TLS 握手或证书错误
+	ErrorClassNetwork           = "network"            // 其他网络错误
+	ErrorClassCanceled          = "canceled"           // 任务被取消
+	ErrorClassGateway           = "http_gateway"       // 502/503/504
+	ErrorClassHTTPStatus        = "http_status"        // 其他非 2xx 状态码
+	ErrorClassCloudFlare        = "cf_challenge"       // 遇到 CloudFlare 验证
+	ErrorClassThrottled         = "throttled"          // 等待域名限制时任务到期
+	ErrorClassRobots            = "robots_disallowed"  // robots.txt 不允许爬取
+	ErrorClassRobotsUnavailable = "robots_unavailable" // robots.txt 返回 5xx 或网络错误，无法判断是否允许
+)
+
+// RetryPolicy 爬取失败时的重试策略
+type RetryPolicy struct {
+	MaxAttempts    int           // 最多尝试次数，包含第一次
+	InitialBackoff time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	MaxBackoff     time.Duration // 重试等待时间上限
+	Jitter         float64       // 等待时间的随机抖动比例，0~1
+	RetryOn        []string      // 可重试的错误分类
+}
+
+// DefaultRetryPolicy 默认重试策略：超时、连接重置/拒绝和网关错误最多尝试 3 次
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     10 * time.Second,
+		Jitter:         0.2,
+		RetryOn:        []string{ErrorClassTimeout, ErrorClassConnReset, ErrorClassConnRefused, ErrorClassGateway},
+	}
+}
+
+// Retryable 判断该错误分类是否允许重试
+func (p RetryPolicy) Retryable(class string) bool {
+	for _, c := range p.RetryOn {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff 返回第 attempt 次尝试失败后的等待时间
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if p.Jitter > 0 && backoff > 0 {
+		backoff += time.Duration(rand.Float64() * p.Jitter * float64(backoff))
+	}
+	return backoff
+}
+
+// WithOverride 使用任务下发的参数覆盖重试次数和初始等待时间，非正数表示不覆盖
+func (p RetryPolicy) WithOverride(maxAttempts int, initialBackoff time.Duration) RetryPolicy {
+	if maxAttempts > 0 {
+		p.MaxAttempts = maxAttempts
+	}
+	if initialBackoff > 0 {
+		p.InitialBackoff = initialBackoff
+		if p.MaxBackoff < initialBackoff {
+			p.MaxBackoff = initialBackoff
+		}
+	}
+	return p
+}
+
+// classifyError 对请求错误进行分类
+func classifyError(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return ErrorClassTimeout
+		}
+		return ErrorClassDNS
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorClassConnReset
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorClassConnRefused
+	}
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var authErr x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	if errors.As(err, &recordErr) || errors.As(err, &certErr) || errors.As(err, &authErr) || errors.As(err, &hostErr) {
+		return ErrorClassTLS
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "connection reset"):
+		return ErrorClassConnReset
+	case strings.Contains(msg, "connection refused"):
+		return ErrorClassConnRefused
+	case strings.Contains(msg, "tls:"):
+		return ErrorClassTLS
+	}
+	return ErrorClassNetwork
+}
+
+// classifyStatus 对非 2xx 状态码进行分类
+func classifyStatus(statusCode int) string {
+	switch statusCode {
+	case 502, 503, 504:
+		return ErrorClassGateway
+	}
+	return ErrorClassHTTPStatus
+}
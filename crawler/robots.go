@@ -0,0 +1,271 @@
+package crawler
+
+import (
+	"agent/logging"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+const (
+	// maxRobotsSize robots.txt 只解析前 500 KiB
+	maxRobotsSize = 500 << 10
+	// maxCrawlDelay Crawl-delay 的上限，避免个别站点设置过大的值占住任务
+	maxCrawlDelay = time.Minute
+	// robotsErrorExpiry robots.txt 无法获取时缓存该状态的时间
+	robotsErrorExpiry = 5 * time.Minute
+	// maxRobotsEntries 超过该数量时清理过期的缓存
+	maxRobotsEntries = 4096
+)
+
+// robotsRule 一条 Allow 或 Disallow 规则
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsRules 适用于当前 User-Agent 的规则
+type robotsRules struct {
+	rules       []robotsRule
+	crawlDelay  time.Duration
+	unavailable string // robots.txt 无法获取的原因，不为空时不爬取
+}
+
+// robotsEntry 缓存的 robots.txt 解析结果
+type robotsEntry struct {
+	rules   *robotsRules
+	expires time.Time
+}
+
+// robotsGroup 解析过程中的一组 User-agent 及其规则
+type robotsGroup struct {
+	agents []string
+	robotsRules
+}
+
+// parseRobots 解析 robots.txt，返回与 userAgent 最匹配的一组规则：
+// User-agent 为 userAgent 子串的组中取最长的，没有时使用 *，都没有时不限制
+func parseRobots(body string, userAgent string) *robotsRules {
+	var groups []*robotsGroup
+	var cur *robotsGroup
+	inRules := false
+	sc := bufio.NewScanner(strings.NewReader(body))
+	sc.Buffer(make([]byte, 0, 64<<10), maxRobotsSize)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			// 规则之后出现的 User-agent 开始新的一组
+			if cur == nil || inRules {
+				cur = &robotsGroup{}
+				groups = append(groups, cur)
+				inRules = false
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if cur == nil {
+				continue
+			}
+			inRules = true
+			// 空的 Disallow 表示不限制
+			if value != "" {
+				cur.rules = append(cur.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if cur == nil {
+				continue
+			}
+			inRules = true
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				cur.crawlDelay = min(time.Duration(secs*float64(time.Second)), maxCrawlDelay)
+			}
+		}
+	}
+
+	ua := strings.ToLower(userAgent)
+	var best, wildcard *robotsGroup
+	bestLen := 0
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			switch {
+			case agent == "*":
+				if wildcard == nil {
+					wildcard = g
+				}
+			case agent != "" && strings.Contains(ua, agent) && len(agent) > bestLen:
+				best, bestLen = g, len(agent)
+			}
+		}
+	}
+	if best == nil {
+		best = wildcard
+	}
+	if best == nil {
+		return &robotsRules{}
+	}
+	return &best.robotsRules
+}
+
+// allowed path（含查询参数）是否允许访问：匹配最长的规则，长度相同时 Allow 优先
+func (r *robotsRules) allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allow, matched := true, -1
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > matched || (n == matched && rule.allow) {
+			allow, matched = rule.allow, n
+		}
+	}
+	return allow
+}
+
+// robotsMatch 规则是否匹配 path，支持 * 通配和结尾的 $
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	return !anchored || pos == len(path)
+}
+
+// RespectsRobots 该计费类型的任务是否需要遵守 robots.txt
+func (c *Crawler) RespectsRobots(billingType string) bool {
+	c.clientMutex.RLock()
+	defer c.clientMutex.RUnlock()
+	for _, t := range c.options.RobotsBillingTypes {
+		if t == "*" || t == billingType {
+			return true
+		}
+	}
+	return false
+}
+
+// errRobotsThrottled 等待域名限速期间任务结束，未能获取 robots.txt
+var errRobotsThrottled = errors.New("等待域名限速时未能获取 robots.txt")
+
+// robotsFor 获取 rawURL 所在站点适用的 robots.txt 规则，按 cacheExpiry 缓存；
+// 请求 robots.txt 与普通请求一样受域名限速，返回等待限速的时间
+func (c *Crawler) robotsFor(ctx context.Context, client *req.Client, pol *politeness, rawURL string) (*robotsRules, time.Duration, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, 0, fmt.Errorf("无效的URL: %s", rawURL)
+	}
+	c.clientMutex.RLock()
+	userAgent := c.userAgent
+	c.clientMutex.RUnlock()
+	key := c.getCacheKey(c.getDomain(rawURL), userAgent)
+	now := time.Now()
+	c.cacheMutex.RLock()
+	entry, ok := c.robotsCache[key]
+	c.cacheMutex.RUnlock()
+	if ok && now.Before(entry.expires) {
+		return entry.rules, 0, nil
+	}
+
+	release, waited, err := pol.wait(ctx, hostOf(rawURL), 0)
+	if err != nil {
+		return nil, waited, fmt.Errorf("%w: %w", errRobotsThrottled, err)
+	}
+	rules, expiry := c.fetchRobots(ctx, client, u.Scheme+"://"+u.Host+"/robots.txt", userAgent)
+	release()
+	if ctx.Err() != nil {
+		return nil, waited, ctx.Err()
+	}
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+	if len(c.robotsCache) >= maxRobotsEntries {
+		for k, e := range c.robotsCache {
+			if now.After(e.expires) {
+				delete(c.robotsCache, k)
+			}
+		}
+	}
+	c.robotsCache[key] = robotsEntry{rules: rules, expires: now.Add(expiry)}
+	return rules, waited, nil
+}
+
+// fetchRobots 获取并解析 robots.txt，返回规则和缓存时间：
+// 4xx 视为不限制；5xx 或网络错误记为无法获取，并缩短缓存时间以便尽快重试
+func (c *Crawler) fetchRobots(ctx context.Context, client *req.Client, robotsURL, userAgent string) (*robotsRules, time.Duration) {
+	resp, err := client.R().SetContext(ctx).Get(robotsURL)
+	switch {
+	case err != nil:
+		logger.WarnContext(ctx, "获取 robots.txt 失败", "robots_url", robotsURL, logging.KeyError, err)
+		return &robotsRules{unavailable: fmt.Sprintf("获取 robots.txt 失败(%s): %v", classifyError(err), err)}, robotsErrorExpiry
+	case resp.StatusCode >= 500:
+		logger.WarnContext(ctx, "获取 robots.txt 失败", "robots_url", robotsURL, "status", resp.StatusCode)
+		return &robotsRules{unavailable: fmt.Sprintf("获取 robots.txt 失败: HTTP %d", resp.StatusCode)}, robotsErrorExpiry
+	case resp.StatusCode >= 400:
+		return &robotsRules{}, c.cacheExpiry
+	case !resp.IsSuccessState():
+		return &robotsRules{}, robotsErrorExpiry
+	}
+	body := resp.String()
+	if len(body) > maxRobotsSize {
+		body = body[:maxRobotsSize]
+	}
+	return parseRobots(body, userAgent), c.cacheExpiry
+}
+
+// robotsAttempt 检查 rawURL 是否允许爬取：允许时 Attempt.ErrorClass 为空，并返回需遵守的 Crawl-delay；
+// Attempt.Throttle 为请求 robots.txt 前等待域名限速的时间
+func (c *Crawler) robotsAttempt(ctx context.Context, client *req.Client, pol *politeness, rawURL string) (Attempt, time.Duration) {
+	startTime := time.Now()
+	rules, waited, err := c.robotsFor(ctx, client, pol, rawURL)
+	a := Attempt{Throttle: waited}
+	if err != nil {
+		// URL 无效时交给后续请求报错
+		if ctx.Err() == nil {
+			return a, 0
+		}
+		a.Error, a.ErrorClass, a.Duration = err.Error(), classifyError(err), time.Since(startTime)-waited
+		if errors.Is(err, errRobotsThrottled) {
+			a.ErrorClass = ErrorClassThrottled
+			if errors.Is(err, context.Canceled) {
+				a.ErrorClass = ErrorClassCanceled
+			}
+		}
+		return a, 0
+	}
+	if rules.unavailable != "" {
+		a.Error, a.ErrorClass, a.Duration = rules.unavailable, ErrorClassRobotsUnavailable, time.Since(startTime)-waited
+		return a, 0
+	}
+	u, _ := url.Parse(rawURL)
+	if !rules.allowed(u.RequestURI()) {
+		a.Error, a.ErrorClass, a.Duration = "robots.txt 禁止访问该页面", ErrorClassRobots, time.Since(startTime)-waited
+		return a, 0
+	}
+	return a, rules.crawlDelay
+}
@@ -0,0 +1,123 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	body := `
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: Firefox
+Disallow: /
+`
+	rules := parseRobots(body, "Mozilla/5.0 Chrome/120.0")
+	if rules.crawlDelay != 2*time.Second {
+		t.Errorf("crawlDelay = %v, 期望 2s", rules.crawlDelay)
+	}
+	for path, want := range map[string]bool{
+		"/":                      true,
+		"/private/a":             false,
+		"/private/public/a":      true,
+		"/doc/a.pdf":             false,
+		"/doc/a.pdf?download=1":  true,
+		"/privateer":             false,
+		"/public/private/a.html": true,
+	} {
+		if got := rules.allowed(path); got != want {
+			t.Errorf("%s: allowed = %v, 期望 %v", path, got, want)
+		}
+	}
+	if parseRobots(body, "Mozilla/5.0 Firefox/121.0").allowed("/") {
+		t.Error("Firefox 组应禁止全部页面")
+	}
+}
+
+func TestFetchRespectsRobots(t *testing.T) {
+	var pages atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+			return
+		}
+		pages.Add(1)
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	c := NewCrawler()
+	opts := DefaultOptions()
+	opts.Profile = ProfileNone
+	opts.RobotsBillingTypes = []string{"free"}
+	if err := c.SetOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	policy := DefaultRetryPolicy()
+	ctx := context.Background()
+	if res := c.FetchWebData(ctx, srv.URL+"/private/a", policy, "free"); res.Success || res.ErrorClass != ErrorClassRobots || len(res.Attempts) != 1 {
+		t.Fatalf("期望 robots_disallowed 且只有一次尝试, got %+v", res)
+	}
+	if res := c.FetchWebData(ctx, srv.URL+"/private/a", policy, "paid"); !res.Success {
+		t.Fatalf("未开启 robots 的计费类型应正常爬取, got %+v", res)
+	}
+	if res := c.FetchWebData(ctx, srv.URL+"/public", policy, "free"); !res.Success {
+		t.Fatalf("允许的页面应正常爬取, got %+v", res)
+	}
+	if n := pages.Load(); n != 2 {
+		t.Errorf("页面请求次数 = %d, 期望 2", n)
+	}
+}
+
+func TestRobotsUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	c := NewCrawler()
+	opts := DefaultOptions()
+	opts.Profile = ProfileNone
+	opts.RobotsBillingTypes = []string{"*"}
+	if err := c.SetOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	res := c.FetchWebData(context.Background(), srv.URL+"/a", DefaultRetryPolicy(), "free")
+	if res.Success || res.ErrorClass != ErrorClassRobotsUnavailable {
+		t.Fatalf("robots.txt 返回 5xx 时应为 robots_unavailable, got %+v", res)
+	}
+}
+
+func TestRobotsRequestRateLimited(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	c := NewCrawler()
+	opts := DefaultOptions()
+	opts.Profile = ProfileNone
+	opts.RobotsBillingTypes = []string{"*"}
+	opts.HostLimits = []HostLimit{{Pattern: "*", Rate: 10, Burst: 1}}
+	if err := c.SetOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	// robots.txt 用掉了唯一的令牌，页面请求需等待下一个令牌
+	res := c.FetchWebData(context.Background(), srv.URL+"/a", DefaultRetryPolicy(), "free")
+	if !res.Success || res.Throttle < 50*time.Millisecond {
+		t.Fatalf("robots.txt 请求应受域名限速, got %+v", res)
+	}
+}
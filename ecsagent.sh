@@ -16,6 +16,28 @@ check_service() {
     fi
 }
 
+# 未通过参数指定的值取自 ECSAGENT_ 前缀的环境变量，与 Agent 读取的变量名一致
+token="${ECSAGENT_TOKEN}"
+host="${ECSAGENT_HOST}"
+api_port="${ECSAGENT_API_PORT}"
+grpc_port="${ECSAGENT_GRPC_PORT}"
+task_flag="${ECSAGENT_TASK_FLAG}"
+tls_ca="${ECSAGENT_TLS_CA}"
+insecure="${ECSAGENT_INSECURE}"
+admin_addr="${ECSAGENT_ADMIN_ADDR:-127.0.0.1:9108}"
+
+# 等待管理接口的 /healthz 返回成功，最多等待 15 秒
+wait_healthy() {
+    for _ in $(seq 1 15); do
+        if curl -sf "http://${admin_addr}/healthz" >/dev/null 2>&1; then
+            return 0
+        fi
+        check_service || return 1
+        sleep 1
+    done
+    return 1
+}
+
 cleanup_service() {
     _yellow "检测到现有服务，正在停止并清理..."
     systemctl stop ecsagent.service >/dev/null 2>&1
@@ -51,6 +73,14 @@ while [ "$#" -gt 0 ]; do
         task_flag="$2"
         shift 2
         ;;
+    -tls-ca)
+        tls_ca="$2"
+        shift 2
+        ;;
+    -insecure)
+        insecure="true"
+        shift 1
+        ;;
     *)
         _red "未知的选项: $1"
         exit 1
@@ -82,10 +112,16 @@ curl -s https://raw.githubusercontent.com/spiritLHLS/monitor-agent/main/ecsagent
 curl -s https://raw.githubusercontent.com/spiritLHLS/monitor-agent/main/ecsagent.service -o /etc/systemd/system/ecsagent.service
 chmod +x /usr/local/bin/ecsagent
 chmod +x /etc/systemd/system/ecsagent.service
-exec_start="/usr/local/bin/ecsagent -token ${token} -host ${host} -grpc-port ${grpc_port} -api-port ${api_port}"
+exec_start="/usr/local/bin/ecsagent -token ${token} -host ${host} -grpc-port ${grpc_port} -api-port ${api_port} -admin-addr ${admin_addr}"
 if [ -n "$task_flag" ]; then
     exec_start="${exec_start} -task-flag ${task_flag}"
 fi
+if [ -n "$tls_ca" ]; then
+    exec_start="${exec_start} -tls-ca ${tls_ca}"
+fi
+if [ "$insecure" = "true" ]; then
+    exec_start="${exec_start} -insecure"
+fi
 if [ -f "/etc/systemd/system/ecsagent.service" ]; then
     new_exec_start="ExecStart=${exec_start}"
     file_path="/etc/systemd/system/ecsagent.service"
@@ -96,8 +132,7 @@ fi
 systemctl daemon-reload
 systemctl start ecsagent.service
 systemctl enable ecsagent.service
-sleep 3
-if check_service; then
+if wait_healthy; then
     _green "ECS Agent 安装成功并已启动！"
     echo
     _blue "当前配置："
@@ -110,6 +145,14 @@ if check_service; then
     else
         echo "  Task Flag: 默认（普通任务）"
     fi
+    if [ "$insecure" = "true" ]; then
+        _yellow "  通信方式: 明文（-insecure）"
+    else
+        echo "  通信方式: TLS"
+    fi
+    echo
+    _green "就绪状态："
+    curl -s "http://${admin_addr}/readyz"
     echo
     _green "服务状态："
     systemctl status ecsagent.service --no-pager -l
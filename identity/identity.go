@@ -0,0 +1,118 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/imroc/req/v3"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Identity 持久化在本地的 Agent 身份
+type Identity struct {
+	AgentID    string    `json:"agent_id"`
+	Credential string    `json:"credential,omitempty"` // 主控注册时下发的专属凭证
+	CreatedAt  time.Time `json:"created_at"`
+
+	path string // 为空时只保存在内存中
+}
+
+// LoadOrCreate 读取身份文件，不存在时生成新的 Agent ID 并保存
+func LoadOrCreate(path string) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		id := &Identity{path: path}
+		if err := json.Unmarshal(data, id); err != nil {
+			return nil, fmt.Errorf("解析身份文件失败: %v", err)
+		}
+		if id.AgentID == "" {
+			return nil, fmt.Errorf("身份文件缺少 agent_id: %s", path)
+		}
+		return id, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("读取身份文件失败: %v", err)
+	}
+	id := &Identity{
+		AgentID:   NewUUID(),
+		CreatedAt: time.Now(),
+		path:      path,
+	}
+	if err := id.save(); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// NewEphemeral 生成只保存在内存中的身份，重启后 Agent ID 会变化
+func NewEphemeral() *Identity {
+	return &Identity{AgentID: NewUUID(), CreatedAt: time.Now()}
+}
+
+// SetCredential 保存主控下发的专属凭证
+func (i *Identity) SetCredential(credential string) error {
+	if i.Credential == credential {
+		return nil
+	}
+	i.Credential = credential
+	return i.save()
+}
+
+// save 原子地写入身份文件
+func (i *Identity) save() error {
+	if i.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(i.path), 0o700); err != nil {
+		return fmt.Errorf("创建身份文件目录失败: %v", err)
+	}
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := i.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("写入身份文件失败: %v", err)
+	}
+	return os.Rename(tmp, i.path)
+}
+
+// NewUUID 生成随机的 UUID v4
+func NewUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Hostname 返回本机主机名，获取失败时返回空字符串
+func Hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// DetectPublicIP 通过外部服务查询本机公网 IP
+func DetectPublicIP(ctx context.Context, serviceURL string) (string, error) {
+	resp, err := req.C().SetTimeout(10 * time.Second).R().SetContext(ctx).Get(serviceURL)
+	if err != nil {
+		return "", err
+	}
+	if !resp.IsSuccessState() {
+		return "", fmt.Errorf("HTTP请求失败，状态码: %d", resp.StatusCode)
+	}
+	ip := strings.TrimSpace(resp.String())
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("无效的IP地址: %q", ip)
+	}
+	return ip, nil
+}
@@ -0,0 +1,173 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// 调整上限的原因
+const (
+	ReasonProbe    = "probe"    // 延迟和错误率稳定，且上限被用满，加性增大
+	ReasonLatency  = "latency"  // 窗口平均延迟超过基线的 LatencyRatio 倍
+	ReasonErrors   = "errors"   // 窗口内超时等过载错误的比例超过 ErrorRate
+	ReasonPressure = "pressure" // 本机 CPU 或内存紧张
+	ReasonBounds   = "bounds"   // 上下限被修改
+)
+
+// AdaptiveOptions 自适应并发的参数
+type AdaptiveOptions struct {
+	Min int
+	Max int
+
+	LatencyRatio float64 // 窗口平均延迟与基线之比超过该值时收缩
+	ErrorRate    float64 // 窗口内过载错误的比例超过该值时收缩
+	Decrease     float64 // 收缩时上限乘以该系数
+	MinWindow    int     // 每个评估窗口至少的样本数，窗口大小取该值与当前上限的较大者
+
+	// OnAdjust 上限变化后调用，不持有锁
+	OnAdjust func(from, to int, reason string)
+}
+
+// DefaultAdaptiveOptions 默认参数，上下限需另行设置
+func DefaultAdaptiveOptions() AdaptiveOptions {
+	return AdaptiveOptions{
+		LatencyRatio: 2,
+		ErrorRate:    0.2,
+		Decrease:     0.7,
+		MinWindow:    5,
+	}
+}
+
+// baselineWeight 每个窗口的平均延迟计入基线的权重，基线随延迟缓慢变化
+const baselineWeight = 0.1
+
+// Adaptive 按 AIMD 调整 Limiter 的上限：每个窗口结束时，延迟和过载错误率稳定且上限被用满则加 1，
+// 延迟明显升高、过载错误增多或本机资源紧张则按比例收缩，上限始终在 [Min, Max] 之间
+type Adaptive struct {
+	l *Limiter
+
+	mu       sync.Mutex
+	opts     AdaptiveOptions
+	pressure func() bool
+	baseline float64 // 延迟基线，单位为秒，0 表示尚无样本
+	samples  int
+	overload int
+	latSum   float64
+	peak     int // 窗口内占用名额的峰值
+}
+
+// NewAdaptive 以 initial 为初始上限（限制在上下限内）管理 l 的上限
+func NewAdaptive(l *Limiter, initial int, opts AdaptiveOptions) *Adaptive {
+	a := &Adaptive{l: l, opts: normalize(opts)}
+	l.SetLimit(a.clamp(initial))
+	return a
+}
+
+func normalize(opts AdaptiveOptions) AdaptiveOptions {
+	if opts.Min < 1 {
+		opts.Min = 1
+	}
+	if opts.Max < opts.Min {
+		opts.Max = opts.Min
+	}
+	if opts.Decrease <= 0 || opts.Decrease >= 1 {
+		opts.Decrease = DefaultAdaptiveOptions().Decrease
+	}
+	if opts.MinWindow < 1 {
+		opts.MinWindow = 1
+	}
+	return opts
+}
+
+// clamp 调用方需持有锁或在构造时调用
+func (a *Adaptive) clamp(limit int) int {
+	return min(max(limit, a.opts.Min), a.opts.Max)
+}
+
+// SetPressure 设置本机资源紧张的判断，窗口结束时调用
+func (a *Adaptive) SetPressure(pressure func() bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pressure = pressure
+}
+
+// SetBounds 修改上下限，当前上限超出新范围时立即调整
+func (a *Adaptive) SetBounds(minLimit, maxLimit int) {
+	a.mu.Lock()
+	opts := a.opts
+	opts.Min, opts.Max = minLimit, maxLimit
+	a.opts = normalize(opts)
+	from := a.l.Limit()
+	to := a.clamp(from)
+	onAdjust := a.opts.OnAdjust
+	a.mu.Unlock()
+	if to != from {
+		a.l.SetLimit(to)
+		if onAdjust != nil {
+			onAdjust(from, to, ReasonBounds)
+		}
+	}
+}
+
+// Bounds 当前上下限
+func (a *Adaptive) Bounds() (minLimit, maxLimit int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.opts.Min, a.opts.Max
+}
+
+// Observe 记录一个任务的结果，需在释放名额前调用；overload 表示超时、连接重置等可能由并发过高引起的错误
+func (a *Adaptive) Observe(latency time.Duration, overload bool) {
+	limit, inUse := a.l.Limit(), a.l.InUse()
+	a.mu.Lock()
+	a.samples++
+	a.latSum += latency.Seconds()
+	if overload {
+		a.overload++
+	}
+	a.peak = max(a.peak, inUse)
+	if a.samples < max(a.opts.MinWindow, limit) {
+		a.mu.Unlock()
+		return
+	}
+	to, reason := a.evaluate(limit)
+	a.samples, a.overload, a.latSum, a.peak = 0, 0, 0, 0
+	onAdjust := a.opts.OnAdjust
+	a.mu.Unlock()
+	if to != limit {
+		a.l.SetLimit(to)
+		if onAdjust != nil {
+			onAdjust(limit, to, reason)
+		}
+	}
+}
+
+// evaluate 窗口结束时计算新的上限，调用方需持有锁
+func (a *Adaptive) evaluate(limit int) (int, string) {
+	mean := a.latSum / float64(a.samples)
+	reason := ""
+	switch {
+	case a.pressure != nil && a.pressure():
+		reason = ReasonPressure
+	case float64(a.overload)/float64(a.samples) > a.opts.ErrorRate:
+		reason = ReasonErrors
+	case a.baseline > 0 && mean > a.baseline*a.opts.LatencyRatio:
+		reason = ReasonLatency
+	}
+	// 延迟升高的窗口不计入基线，避免基线被拥塞时的延迟抬高
+	if reason != ReasonLatency {
+		if a.baseline == 0 {
+			a.baseline = mean
+		} else {
+			a.baseline += (mean - a.baseline) * baselineWeight
+		}
+	}
+	if reason != "" {
+		return a.clamp(int(math.Floor(float64(limit) * a.opts.Decrease))), reason
+	}
+	if a.peak >= limit {
+		return a.clamp(limit + 1), ReasonProbe
+	}
+	return limit, ""
+}
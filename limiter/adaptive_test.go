@@ -0,0 +1,60 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fill 占满当前上限后按 latency 记录一个窗口的样本
+func fill(t *testing.T, a *Adaptive, l *Limiter, latency time.Duration, overloads int) {
+	t.Helper()
+	limit := l.Limit()
+	for i := 0; i < limit; i++ {
+		if err := l.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < max(limit, a.opts.MinWindow); i++ {
+		a.Observe(latency, i < overloads)
+	}
+	for i := 0; i < limit; i++ {
+		l.Release()
+	}
+}
+
+func TestAdaptiveAIMD(t *testing.T) {
+	l := New(1)
+	var reasons []string
+	opts := DefaultAdaptiveOptions()
+	opts.Min, opts.Max = 2, 6
+	opts.OnAdjust = func(from, to int, reason string) { reasons = append(reasons, reason) }
+	a := NewAdaptive(l, 4, opts)
+
+	fill(t, a, l, 100*time.Millisecond, 0)
+	if l.Limit() != 5 {
+		t.Fatalf("稳定且用满时应加 1, got %d", l.Limit())
+	}
+	fill(t, a, l, 100*time.Millisecond, 0)
+	fill(t, a, l, 100*time.Millisecond, 0)
+	if l.Limit() != 6 {
+		t.Fatalf("不应超过上限, got %d", l.Limit())
+	}
+	fill(t, a, l, time.Second, 0)
+	if l.Limit() != 4 || reasons[len(reasons)-1] != ReasonLatency {
+		t.Fatalf("延迟升高时应收缩到 4, got %d %v", l.Limit(), reasons)
+	}
+	fill(t, a, l, 100*time.Millisecond, 4)
+	if l.Limit() != 2 || reasons[len(reasons)-1] != ReasonErrors {
+		t.Fatalf("过载错误增多时应收缩到下限, got %d %v", l.Limit(), reasons)
+	}
+	a.SetPressure(func() bool { return true })
+	fill(t, a, l, 100*time.Millisecond, 0)
+	if l.Limit() != 2 {
+		t.Fatalf("不应低于下限, got %d", l.Limit())
+	}
+	a.SetBounds(3, 8)
+	if l.Limit() != 3 || reasons[len(reasons)-1] != ReasonBounds {
+		t.Fatalf("修改下限后应立即调整, got %d %v", l.Limit(), reasons)
+	}
+}
@@ -0,0 +1,96 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+)
+
+// Limiter 可在运行中调整上限的并发限制器；调低上限时已占用的名额不受影响，
+// 释放到新上限以下后才放行新的请求
+type Limiter struct {
+	mu      sync.Mutex
+	limit   int
+	inUse   int
+	changed chan struct{} // 名额释放或上限调整时关闭，唤醒等待者
+}
+
+// New 创建上限为 limit 的限制器
+func New(limit int) *Limiter {
+	if limit < 1 {
+		limit = 1
+	}
+	return &Limiter{limit: limit, changed: make(chan struct{})}
+}
+
+// Acquire 占用一个名额，ctx 结束时返回其错误
+func (l *Limiter) Acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inUse < l.limit {
+			l.inUse++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// TryAcquire 有空闲名额时占用并返回 true，不等待
+func (l *Limiter) TryAcquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inUse < l.limit {
+		l.inUse++
+		return true
+	}
+	return false
+}
+
+// Release 释放一个名额
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inUse > 0 {
+		l.inUse--
+	}
+	l.notify()
+}
+
+// SetLimit 调整上限，返回调整前的上限
+func (l *Limiter) SetLimit(limit int) int {
+	if limit < 1 {
+		limit = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	previous := l.limit
+	l.limit = limit
+	l.notify()
+	return previous
+}
+
+// Limit 当前上限
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// InUse 已占用的名额
+func (l *Limiter) InUse() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inUse
+}
+
+// notify 唤醒所有等待者，调用方需持有锁
+func (l *Limiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -0,0 +1,233 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 任务相关日志统一使用的字段名
+const (
+	KeyTag        = "tag"
+	KeyURL        = "url"
+	KeyMode       = "mode"
+	KeyFlag       = "flag"
+	KeyAttempt    = "attempt"
+	KeyDuration   = "duration"
+	KeyErrorClass = "error_class"
+	KeyError      = "error"
+	KeyToken      = "token"
+	KeySubsystem  = "subsystem"
+	KeyTraceID    = "trace_id"
+)
+
+// secretKeys 输出时经 MaskToken 遮蔽的字段
+var secretKeys = map[string]bool{
+	KeyToken:      true,
+	"credential":  true,
+	"hmac_secret": true,
+}
+
+// 输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	// root 实际输出日志的 handler，Setup 之前输出到 stderr 的文本格式
+	root atomic.Pointer[slog.Handler]
+
+	level slog.LevelVar
+
+	subsystemMu     sync.RWMutex
+	subsystemLevels map[string]slog.Level
+)
+
+func init() {
+	level.Set(slog.LevelInfo)
+	h := newHandler(FormatText, os.Stderr)
+	root.Store(&h)
+}
+
+// ParseLevel 解析日志级别名称 (debug, info, warn, error)
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("无效的日志级别: %s (可选: debug, info, warn, error)", name)
+	}
+}
+
+// ParseSubsystemLevels 解析子系统日志级别，格式如 controller=debug,crawler=warn
+func ParseSubsystemLevels(spec string) (map[string]slog.Level, error) {
+	levels := map[string]slog.Level{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, levelName, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("无效的子系统日志级别: %s (格式: controller=debug)", item)
+		}
+		l, err := ParseLevel(levelName)
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(name)] = l
+	}
+	return levels, nil
+}
+
+// Setup 设置输出格式 (text, json) 和输出位置，并接管标准库 log 的输出
+func Setup(format string, w io.Writer) error {
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("无效的日志格式: %s (可选: text, json)", format)
+	}
+	h := newHandler(format, w)
+	root.Store(&h)
+	slog.SetDefault(For("agent"))
+	return nil
+}
+
+// SetLevel 设置全局日志级别，可在运行中修改
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// GetLevel 当前全局日志级别
+func GetLevel() slog.Level {
+	return level.Level()
+}
+
+// SetSubsystemLevels 设置各子系统的日志级别，未列出的子系统使用全局级别
+func SetSubsystemLevels(levels map[string]slog.Level) {
+	subsystemMu.Lock()
+	defer subsystemMu.Unlock()
+	subsystemLevels = levels
+}
+
+// levelFor 子系统当前生效的日志级别
+func levelFor(subsystem string) slog.Level {
+	subsystemMu.RLock()
+	defer subsystemMu.RUnlock()
+	if l, ok := subsystemLevels[subsystem]; ok {
+		return l
+	}
+	return level.Level()
+}
+
+// FormatSubsystemLevels 子系统日志级别的文本形式，与 ParseSubsystemLevels 对应
+func FormatSubsystemLevels(levels map[string]slog.Level) string {
+	items := make([]string, 0, len(levels))
+	for name, l := range levels {
+		items = append(items, name+"="+strings.ToLower(l.String()))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// For 返回子系统的 logger，级别随 SetLevel/SetSubsystemLevels 动态变化
+func For(subsystem string) *slog.Logger {
+	return slog.New(&handler{subsystem: subsystem})
+}
+
+// MaskToken 遮蔽 token 用于日志输出
+func MaskToken(token string) string {
+	if len(token) <= 4 {
+		return "****"
+	}
+	return token[:2] + "****" + token[len(token)-2:]
+}
+
+// newHandler 创建输出 handler，级别由外层 handler 过滤，secretKeys 中的字段会被遮蔽
+func newHandler(format string, w io.Writer) slog.Handler {
+	opts := &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if secretKeys[a.Key] && a.Value.Kind() == slog.KindString {
+				return slog.String(a.Key, MaskToken(a.Value.String()))
+			}
+			return a
+		},
+	}
+	if format == FormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+type contextKey struct{}
+
+// WithAttrs 在 ctx 中附加日志字段，使用该 ctx 的 *Context 日志方法都会带上这些字段
+func WithAttrs(ctx context.Context, args ...any) context.Context {
+	attrs := append(Attrs(ctx), argsToAttrs(args)...)
+	return context.WithValue(ctx, contextKey{}, attrs)
+}
+
+// Attrs ctx 中附加的日志字段
+func Attrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return attrs[:len(attrs):len(attrs)]
+}
+
+func argsToAttrs(args []any) []slog.Attr {
+	var r slog.Record
+	r.Add(args...)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return attrs
+}
+
+// handler 按子系统过滤级别后交给当前的 root handler 输出
+type handler struct {
+	subsystem string
+	wrap      []func(slog.Handler) slog.Handler // WithAttrs/WithGroup 的调用，输出时依次应用
+}
+
+func (h *handler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= levelFor(h.subsystem)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	out := (*root.Load()).WithAttrs([]slog.Attr{slog.String(KeySubsystem, h.subsystem)})
+	if attrs := Attrs(ctx); len(attrs) > 0 {
+		out = out.WithAttrs(attrs)
+	}
+	for _, wrap := range h.wrap {
+		out = wrap(out)
+	}
+	return out.Handle(ctx, r)
+}
+
+func (h *handler) with(wrap func(slog.Handler) slog.Handler) *handler {
+	return &handler{subsystem: h.subsystem, wrap: append(h.wrap[:len(h.wrap):len(h.wrap)], wrap)}
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithGroup(name) })
+}
@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSubsystemLevelsAndRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup(FormatJSON, &buf); err != nil {
+		t.Fatal(err)
+	}
+	defer Setup(FormatText, os.Stderr)
+	levels, err := ParseSubsystemLevels("controller=debug, crawler=warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetLevel(slog.LevelInfo)
+	SetSubsystemLevels(levels)
+	defer SetSubsystemLevels(nil)
+
+	ctx := WithAttrs(context.Background(), KeyTag, "t1", KeyURL, "https://example.com")
+	For("controller").DebugContext(ctx, "controller debug", KeyToken, "secret-token")
+	For("crawler").InfoContext(ctx, "crawler info")
+	For("agent").Debug("agent debug")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("应只输出 controller 的 debug 日志, got %d 行: %s", len(lines), buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry[KeySubsystem] != "controller" || entry[KeyTag] != "t1" || entry[KeyURL] != "https://example.com" {
+		t.Fatalf("缺少字段: %v", entry)
+	}
+	if entry[KeyToken] != MaskToken("secret-token") {
+		t.Fatalf("token 未遮蔽: %v", entry[KeyToken])
+	}
+}
@@ -25,6 +25,7 @@ type TaskRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
 	Flag          string                 `protobuf:"bytes,2,opt,name=flag,proto3" json:"flag,omitempty"`
+	Agent         *AgentInfo             `protobuf:"bytes,3,opt,name=agent,proto3" json:"agent,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -73,17 +74,33 @@ func (x *TaskRequest) GetFlag() string {
 	return ""
 }
 
+func (x *TaskRequest) GetAgent() *AgentInfo {
+	if x != nil {
+		return x.Agent
+	}
+	return nil
+}
+
 type CrawlerTask struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
-	Tag           string                 `protobuf:"bytes,2,opt,name=tag,proto3" json:"tag,omitempty"`
-	Url           string                 `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
-	BillingType   string                 `protobuf:"bytes,4,opt,name=billing_type,json=billingType,proto3" json:"billing_type,omitempty"`
-	CrawlNum      int32                  `protobuf:"varint,5,opt,name=crawl_num,json=crawlNum,proto3" json:"crawl_num,omitempty"`
-	ExtraHeader   string                 `protobuf:"bytes,6,opt,name=extra_header,json=extraHeader,proto3" json:"extra_header,omitempty"`
-	ReqMethod     string                 `protobuf:"bytes,7,opt,name=req_method,json=reqMethod,proto3" json:"req_method,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Token          string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Tag            string                 `protobuf:"bytes,2,opt,name=tag,proto3" json:"tag,omitempty"`
+	Url            string                 `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+	BillingType    string                 `protobuf:"bytes,4,opt,name=billing_type,json=billingType,proto3" json:"billing_type,omitempty"`
+	CrawlNum       int32                  `protobuf:"varint,5,opt,name=crawl_num,json=crawlNum,proto3" json:"crawl_num,omitempty"`
+	ExtraHeader    string                 `protobuf:"bytes,6,opt,name=extra_header,json=extraHeader,proto3" json:"extra_header,omitempty"`
+	ReqMethod      string                 `protobuf:"bytes,7,opt,name=req_method,json=reqMethod,proto3" json:"req_method,omitempty"`
+	Deadline       int64                  `protobuf:"varint,8,opt,name=deadline,proto3" json:"deadline,omitempty"`
+	MaxAttempts    int32                  `protobuf:"varint,9,opt,name=max_attempts,json=maxAttempts,proto3" json:"max_attempts,omitempty"`
+	RetryBackoffMs int32                  `protobuf:"varint,10,opt,name=retry_backoff_ms,json=retryBackoffMs,proto3" json:"retry_backoff_ms,omitempty"`
+	Timestamp      int64                  `protobuf:"varint,11,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Nonce          string                 `protobuf:"bytes,12,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Signature      string                 `protobuf:"bytes,13,opt,name=signature,proto3" json:"signature,omitempty"`
+	TraceId        string                 `protobuf:"bytes,14,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`
+	Priority       int32                  `protobuf:"varint,15,opt,name=priority,proto3" json:"priority,omitempty"`
+	TtlMs          int64                  `protobuf:"varint,16,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *CrawlerTask) Reset() {
@@ -165,6 +182,69 @@ func (x *CrawlerTask) GetReqMethod() string {
 	return ""
 }
 
+func (x *CrawlerTask) GetDeadline() int64 {
+	if x != nil {
+		return x.Deadline
+	}
+	return 0
+}
+
+func (x *CrawlerTask) GetMaxAttempts() int32 {
+	if x != nil {
+		return x.MaxAttempts
+	}
+	return 0
+}
+
+func (x *CrawlerTask) GetRetryBackoffMs() int32 {
+	if x != nil {
+		return x.RetryBackoffMs
+	}
+	return 0
+}
+
+func (x *CrawlerTask) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *CrawlerTask) GetNonce() string {
+	if x != nil {
+		return x.Nonce
+	}
+	return ""
+}
+
+func (x *CrawlerTask) GetSignature() string {
+	if x != nil {
+		return x.Signature
+	}
+	return ""
+}
+
+func (x *CrawlerTask) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *CrawlerTask) GetPriority() int32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *CrawlerTask) GetTtlMs() int64 {
+	if x != nil {
+		return x.TtlMs
+	}
+	return 0
+}
+
 type CrawlerResult struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
@@ -177,6 +257,16 @@ type CrawlerResult struct {
 	Success       bool                   `protobuf:"varint,8,opt,name=success,proto3" json:"success,omitempty"`
 	ReqMethod     string                 `protobuf:"bytes,9,opt,name=req_method,json=reqMethod,proto3" json:"req_method,omitempty"`
 	WebData       string                 `protobuf:"bytes,10,opt,name=web_data,json=webData,proto3" json:"web_data,omitempty"`
+	AttemptCount  int32                  `protobuf:"varint,11,opt,name=attempt_count,json=attemptCount,proto3" json:"attempt_count,omitempty"`
+	Attempts      []*CrawlAttempt        `protobuf:"bytes,12,rep,name=attempts,proto3" json:"attempts,omitempty"`
+	ErrorClass    string                 `protobuf:"bytes,13,opt,name=error_class,json=errorClass,proto3" json:"error_class,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,14,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Nonce         string                 `protobuf:"bytes,15,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Signature     string                 `protobuf:"bytes,16,opt,name=signature,proto3" json:"signature,omitempty"`
+	Agent         *AgentInfo             `protobuf:"bytes,17,opt,name=agent,proto3" json:"agent,omitempty"`
+	TraceId       string                 `protobuf:"bytes,18,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`
+	ThrottleMs    int64                  `protobuf:"varint,19,opt,name=throttle_ms,json=throttleMs,proto3" json:"throttle_ms,omitempty"`
+	Coalesced     bool                   `protobuf:"varint,20,opt,name=coalesced,proto3" json:"coalesced,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -281,6 +371,152 @@ func (x *CrawlerResult) GetWebData() string {
 	return ""
 }
 
+func (x *CrawlerResult) GetAttemptCount() int32 {
+	if x != nil {
+		return x.AttemptCount
+	}
+	return 0
+}
+
+func (x *CrawlerResult) GetAttempts() []*CrawlAttempt {
+	if x != nil {
+		return x.Attempts
+	}
+	return nil
+}
+
+func (x *CrawlerResult) GetErrorClass() string {
+	if x != nil {
+		return x.ErrorClass
+	}
+	return ""
+}
+
+func (x *CrawlerResult) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *CrawlerResult) GetNonce() string {
+	if x != nil {
+		return x.Nonce
+	}
+	return ""
+}
+
+func (x *CrawlerResult) GetSignature() string {
+	if x != nil {
+		return x.Signature
+	}
+	return ""
+}
+
+func (x *CrawlerResult) GetAgent() *AgentInfo {
+	if x != nil {
+		return x.Agent
+	}
+	return nil
+}
+
+func (x *CrawlerResult) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *CrawlerResult) GetThrottleMs() int64 {
+	if x != nil {
+		return x.ThrottleMs
+	}
+	return 0
+}
+
+func (x *CrawlerResult) GetCoalesced() bool {
+	if x != nil {
+		return x.Coalesced
+	}
+	return false
+}
+
+type CrawlAttempt struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Error         string                 `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	ErrorClass    string                 `protobuf:"bytes,2,opt,name=error_class,json=errorClass,proto3" json:"error_class,omitempty"`
+	StatusCode    int32                  `protobuf:"varint,3,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	DurationMs    int64                  `protobuf:"varint,4,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	ThrottleMs    int64                  `protobuf:"varint,5,opt,name=throttle_ms,json=throttleMs,proto3" json:"throttle_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CrawlAttempt) Reset() {
+	*x = CrawlAttempt{}
+	mi := &file_client_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CrawlAttempt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CrawlAttempt) ProtoMessage() {}
+
+func (x *CrawlAttempt) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CrawlAttempt.ProtoReflect.Descriptor instead.
+func (*CrawlAttempt) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CrawlAttempt) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *CrawlAttempt) GetErrorClass() string {
+	if x != nil {
+		return x.ErrorClass
+	}
+	return ""
+}
+
+func (x *CrawlAttempt) GetStatusCode() int32 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+func (x *CrawlAttempt) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *CrawlAttempt) GetThrottleMs() int64 {
+	if x != nil {
+		return x.ThrottleMs
+	}
+	return 0
+}
+
 type HandleResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -291,7 +527,7 @@ type HandleResponse struct {
 
 func (x *HandleResponse) Reset() {
 	*x = HandleResponse{}
-	mi := &file_client_proto_msgTypes[3]
+	mi := &file_client_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -303,7 +539,7 @@ func (x *HandleResponse) String() string {
 func (*HandleResponse) ProtoMessage() {}
 
 func (x *HandleResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[3]
+	mi := &file_client_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -316,7 +552,7 @@ func (x *HandleResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HandleResponse.ProtoReflect.Descriptor instead.
 func (*HandleResponse) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{3}
+	return file_client_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *HandleResponse) GetSuccess() bool {
@@ -343,7 +579,7 @@ type ControlRequest struct {
 
 func (x *ControlRequest) Reset() {
 	*x = ControlRequest{}
-	mi := &file_client_proto_msgTypes[4]
+	mi := &file_client_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -355,7 +591,7 @@ func (x *ControlRequest) String() string {
 func (*ControlRequest) ProtoMessage() {}
 
 func (x *ControlRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[4]
+	mi := &file_client_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -368,7 +604,7 @@ func (x *ControlRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ControlRequest.ProtoReflect.Descriptor instead.
 func (*ControlRequest) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{4}
+	return file_client_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *ControlRequest) GetToken() string {
@@ -395,7 +631,7 @@ type ControlResponse struct {
 
 func (x *ControlResponse) Reset() {
 	*x = ControlResponse{}
-	mi := &file_client_proto_msgTypes[5]
+	mi := &file_client_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -407,7 +643,7 @@ func (x *ControlResponse) String() string {
 func (*ControlResponse) ProtoMessage() {}
 
 func (x *ControlResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[5]
+	mi := &file_client_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -420,7 +656,7 @@ func (x *ControlResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ControlResponse.ProtoReflect.Descriptor instead.
 func (*ControlResponse) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{5}
+	return file_client_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *ControlResponse) GetStatus() bool {
@@ -446,7 +682,7 @@ type StatusRequest struct {
 
 func (x *StatusRequest) Reset() {
 	*x = StatusRequest{}
-	mi := &file_client_proto_msgTypes[6]
+	mi := &file_client_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -458,7 +694,7 @@ func (x *StatusRequest) String() string {
 func (*StatusRequest) ProtoMessage() {}
 
 func (x *StatusRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[6]
+	mi := &file_client_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -471,7 +707,7 @@ func (x *StatusRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
 func (*StatusRequest) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{6}
+	return file_client_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *StatusRequest) GetToken() string {
@@ -491,7 +727,7 @@ type StatusResponse struct {
 
 func (x *StatusResponse) Reset() {
 	*x = StatusResponse{}
-	mi := &file_client_proto_msgTypes[7]
+	mi := &file_client_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -503,7 +739,7 @@ func (x *StatusResponse) String() string {
 func (*StatusResponse) ProtoMessage() {}
 
 func (x *StatusResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[7]
+	mi := &file_client_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -516,7 +752,7 @@ func (x *StatusResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
 func (*StatusResponse) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{7}
+	return file_client_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *StatusResponse) GetStatus() bool {
@@ -533,57 +769,764 @@ func (x *StatusResponse) GetMessage() string {
 	return ""
 }
 
-var File_client_proto protoreflect.FileDescriptor
+type AgentInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AgentId       string                 `protobuf:"bytes,1,opt,name=agent_id,json=agentId,proto3" json:"agent_id,omitempty"`
+	Hostname      string                 `protobuf:"bytes,2,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Version       string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	PublicIp      string                 `protobuf:"bytes,4,opt,name=public_ip,json=publicIp,proto3" json:"public_ip,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_client_proto_rawDesc = "" +
-	"\n" +
-	"\fclient.proto\x12\aspiders\"7\n" +
-	"\vTaskRequest\x12\x14\n" +
-	"\x05token\x18\x01 \x01(\tR\x05token\x12\x12\n" +
-	"\x04flag\x18\x02 \x01(\tR\x04flag\"\xc9\x01\n" +
-	"\vCrawlerTask\x12\x14\n" +
-	"\x05token\x18\x01 \x01(\tR\x05token\x12\x10\n" +
-	"\x03tag\x18\x02 \x01(\tR\x03tag\x12\x10\n" +
-	"\x03url\x18\x03 \x01(\tR\x03url\x12!\n" +
-	"\fbilling_type\x18\x04 \x01(\tR\vbillingType\x12\x1b\n" +
-	"\tcrawl_num\x18\x05 \x01(\x05R\bcrawlNum\x12!\n" +
-	"\fextra_header\x18\x06 \x01(\tR\vextraHeader\x12\x1d\n" +
-	"\n" +
-	"req_method\x18\a \x01(\tR\treqMethod\"\x96\x02\n" +
-	"\rCrawlerResult\x12\x14\n" +
-	"\x05token\x18\x01 \x01(\tR\x05token\x12\x10\n" +
-	"\x03tag\x18\x02 \x01(\tR\x03tag\x12\x10\n" +
-	"\x03url\x18\x03 \x01(\tR\x03url\x12!\n" +
-	"\fbilling_type\x18\x04 \x01(\tR\vbillingType\x12\x1b\n" +
-	"\tcrawl_num\x18\x05 \x01(\x05R\bcrawlNum\x12\x18\n" +
-	"\aruntime\x18\x06 \x01(\x05R\aruntime\x12\x1d\n" +
-	"\n" +
-	"start_time\x18\a \x01(\tR\tstartTime\x12\x18\n" +
-	"\asuccess\x18\b \x01(\bR\asuccess\x12\x1d\n" +
-	"\n" +
-	"req_method\x18\t \x01(\tR\treqMethod\x12\x19\n" +
-	"\bweb_data\x18\n" +
-	" \x01(\tR\awebData\"D\n" +
-	"\x0eHandleResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"M\n" +
-	"\x0eControlRequest\x12\x14\n" +
-	"\x05token\x18\x01 \x01(\tR\x05token\x12%\n" +
-	"\x0eenable_spiders\x18\x02 \x01(\bR\renableSpiders\"C\n" +
-	"\x0fControlResponse\x12\x16\n" +
-	"\x06status\x18\x01 \x01(\bR\x06status\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"%\n" +
-	"\rStatusRequest\x12\x14\n" +
-	"\x05token\x18\x01 \x01(\tR\x05token\"B\n" +
-	"\x0eStatusResponse\x12\x16\n" +
-	"\x06status\x18\x01 \x01(\bR\x06status\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage2\x97\x02\n" +
+func (x *AgentInfo) Reset() {
+	*x = AgentInfo{}
+	mi := &file_client_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentInfo) ProtoMessage() {}
+
+func (x *AgentInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentInfo.ProtoReflect.Descriptor instead.
+func (*AgentInfo) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *AgentInfo) GetAgentId() string {
+	if x != nil {
+		return x.AgentId
+	}
+	return ""
+}
+
+func (x *AgentInfo) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *AgentInfo) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *AgentInfo) GetPublicIp() string {
+	if x != nil {
+		return x.PublicIp
+	}
+	return ""
+}
+
+type RegisterRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Agent         *AgentInfo             `protobuf:"bytes,2,opt,name=agent,proto3" json:"agent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterRequest) Reset() {
+	*x = RegisterRequest{}
+	mi := &file_client_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterRequest) ProtoMessage() {}
+
+func (x *RegisterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterRequest.ProtoReflect.Descriptor instead.
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RegisterRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetAgent() *AgentInfo {
+	if x != nil {
+		return x.Agent
+	}
+	return nil
+}
+
+type RegisterResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	AgentToken    string                 `protobuf:"bytes,3,opt,name=agent_token,json=agentToken,proto3" json:"agent_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterResponse) Reset() {
+	*x = RegisterResponse{}
+	mi := &file_client_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterResponse) ProtoMessage() {}
+
+func (x *RegisterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterResponse.ProtoReflect.Descriptor instead.
+func (*RegisterResponse) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RegisterResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *RegisterResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *RegisterResponse) GetAgentToken() string {
+	if x != nil {
+		return x.AgentToken
+	}
+	return ""
+}
+
+type StatusReport struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Agent         *AgentInfo             `protobuf:"bytes,2,opt,name=agent,proto3" json:"agent,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Host          *HostStats             `protobuf:"bytes,4,opt,name=host,proto3" json:"host,omitempty"`
+	Runtime       *RuntimeStats          `protobuf:"bytes,5,opt,name=runtime,proto3" json:"runtime,omitempty"`
+	Load          *AgentLoad             `protobuf:"bytes,6,opt,name=load,proto3" json:"load,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusReport) Reset() {
+	*x = StatusReport{}
+	mi := &file_client_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusReport) ProtoMessage() {}
+
+func (x *StatusReport) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusReport.ProtoReflect.Descriptor instead.
+func (*StatusReport) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *StatusReport) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *StatusReport) GetAgent() *AgentInfo {
+	if x != nil {
+		return x.Agent
+	}
+	return nil
+}
+
+func (x *StatusReport) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *StatusReport) GetHost() *HostStats {
+	if x != nil {
+		return x.Host
+	}
+	return nil
+}
+
+func (x *StatusReport) GetRuntime() *RuntimeStats {
+	if x != nil {
+		return x.Runtime
+	}
+	return nil
+}
+
+func (x *StatusReport) GetLoad() *AgentLoad {
+	if x != nil {
+		return x.Load
+	}
+	return nil
+}
+
+type HostStats struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	NumCpu            int32                  `protobuf:"varint,1,opt,name=num_cpu,json=numCpu,proto3" json:"num_cpu,omitempty"`
+	Load1             float64                `protobuf:"fixed64,2,opt,name=load1,proto3" json:"load1,omitempty"`
+	Load5             float64                `protobuf:"fixed64,3,opt,name=load5,proto3" json:"load5,omitempty"`
+	Load15            float64                `protobuf:"fixed64,4,opt,name=load15,proto3" json:"load15,omitempty"`
+	CpuPercent        float64                `protobuf:"fixed64,5,opt,name=cpu_percent,json=cpuPercent,proto3" json:"cpu_percent,omitempty"`
+	MemTotalBytes     uint64                 `protobuf:"varint,6,opt,name=mem_total_bytes,json=memTotalBytes,proto3" json:"mem_total_bytes,omitempty"`
+	MemAvailableBytes uint64                 `protobuf:"varint,7,opt,name=mem_available_bytes,json=memAvailableBytes,proto3" json:"mem_available_bytes,omitempty"`
+	NetRxBytesPerSec  float64                `protobuf:"fixed64,8,opt,name=net_rx_bytes_per_sec,json=netRxBytesPerSec,proto3" json:"net_rx_bytes_per_sec,omitempty"`
+	NetTxBytesPerSec  float64                `protobuf:"fixed64,9,opt,name=net_tx_bytes_per_sec,json=netTxBytesPerSec,proto3" json:"net_tx_bytes_per_sec,omitempty"`
+	TcpConnections    int32                  `protobuf:"varint,10,opt,name=tcp_connections,json=tcpConnections,proto3" json:"tcp_connections,omitempty"`
+	UptimeSeconds     int64                  `protobuf:"varint,11,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *HostStats) Reset() {
+	*x = HostStats{}
+	mi := &file_client_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HostStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HostStats) ProtoMessage() {}
+
+func (x *HostStats) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HostStats.ProtoReflect.Descriptor instead.
+func (*HostStats) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *HostStats) GetNumCpu() int32 {
+	if x != nil {
+		return x.NumCpu
+	}
+	return 0
+}
+
+func (x *HostStats) GetLoad1() float64 {
+	if x != nil {
+		return x.Load1
+	}
+	return 0
+}
+
+func (x *HostStats) GetLoad5() float64 {
+	if x != nil {
+		return x.Load5
+	}
+	return 0
+}
+
+func (x *HostStats) GetLoad15() float64 {
+	if x != nil {
+		return x.Load15
+	}
+	return 0
+}
+
+func (x *HostStats) GetCpuPercent() float64 {
+	if x != nil {
+		return x.CpuPercent
+	}
+	return 0
+}
+
+func (x *HostStats) GetMemTotalBytes() uint64 {
+	if x != nil {
+		return x.MemTotalBytes
+	}
+	return 0
+}
+
+func (x *HostStats) GetMemAvailableBytes() uint64 {
+	if x != nil {
+		return x.MemAvailableBytes
+	}
+	return 0
+}
+
+func (x *HostStats) GetNetRxBytesPerSec() float64 {
+	if x != nil {
+		return x.NetRxBytesPerSec
+	}
+	return 0
+}
+
+func (x *HostStats) GetNetTxBytesPerSec() float64 {
+	if x != nil {
+		return x.NetTxBytesPerSec
+	}
+	return 0
+}
+
+func (x *HostStats) GetTcpConnections() int32 {
+	if x != nil {
+		return x.TcpConnections
+	}
+	return 0
+}
+
+func (x *HostStats) GetUptimeSeconds() int64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+type RuntimeStats struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	GoVersion      string                 `protobuf:"bytes,1,opt,name=go_version,json=goVersion,proto3" json:"go_version,omitempty"`
+	BuildVersion   string                 `protobuf:"bytes,2,opt,name=build_version,json=buildVersion,proto3" json:"build_version,omitempty"`
+	VcsRevision    string                 `protobuf:"bytes,3,opt,name=vcs_revision,json=vcsRevision,proto3" json:"vcs_revision,omitempty"`
+	Goroutines     int32                  `protobuf:"varint,4,opt,name=goroutines,proto3" json:"goroutines,omitempty"`
+	HeapAllocBytes uint64                 `protobuf:"varint,5,opt,name=heap_alloc_bytes,json=heapAllocBytes,proto3" json:"heap_alloc_bytes,omitempty"`
+	SysBytes       uint64                 `protobuf:"varint,6,opt,name=sys_bytes,json=sysBytes,proto3" json:"sys_bytes,omitempty"`
+	NumGc          uint32                 `protobuf:"varint,7,opt,name=num_gc,json=numGc,proto3" json:"num_gc,omitempty"`
+	UptimeSeconds  int64                  `protobuf:"varint,8,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RuntimeStats) Reset() {
+	*x = RuntimeStats{}
+	mi := &file_client_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RuntimeStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RuntimeStats) ProtoMessage() {}
+
+func (x *RuntimeStats) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RuntimeStats.ProtoReflect.Descriptor instead.
+func (*RuntimeStats) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *RuntimeStats) GetGoVersion() string {
+	if x != nil {
+		return x.GoVersion
+	}
+	return ""
+}
+
+func (x *RuntimeStats) GetBuildVersion() string {
+	if x != nil {
+		return x.BuildVersion
+	}
+	return ""
+}
+
+func (x *RuntimeStats) GetVcsRevision() string {
+	if x != nil {
+		return x.VcsRevision
+	}
+	return ""
+}
+
+func (x *RuntimeStats) GetGoroutines() int32 {
+	if x != nil {
+		return x.Goroutines
+	}
+	return 0
+}
+
+func (x *RuntimeStats) GetHeapAllocBytes() uint64 {
+	if x != nil {
+		return x.HeapAllocBytes
+	}
+	return 0
+}
+
+func (x *RuntimeStats) GetSysBytes() uint64 {
+	if x != nil {
+		return x.SysBytes
+	}
+	return 0
+}
+
+func (x *RuntimeStats) GetNumGc() uint32 {
+	if x != nil {
+		return x.NumGc
+	}
+	return 0
+}
+
+func (x *RuntimeStats) GetUptimeSeconds() int64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+type AgentLoad struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	InflightTasks int32                  `protobuf:"varint,1,opt,name=inflight_tasks,json=inflightTasks,proto3" json:"inflight_tasks,omitempty"`
+	MaxConcurrent int32                  `protobuf:"varint,2,opt,name=max_concurrent,json=maxConcurrent,proto3" json:"max_concurrent,omitempty"`
+	TransportMode string                 `protobuf:"bytes,3,opt,name=transport_mode,json=transportMode,proto3" json:"transport_mode,omitempty"`
+	SpoolDepth    int32                  `protobuf:"varint,4,opt,name=spool_depth,json=spoolDepth,proto3" json:"spool_depth,omitempty"`
+	Paused        bool                   `protobuf:"varint,5,opt,name=paused,proto3" json:"paused,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AgentLoad) Reset() {
+	*x = AgentLoad{}
+	mi := &file_client_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentLoad) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentLoad) ProtoMessage() {}
+
+func (x *AgentLoad) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentLoad.ProtoReflect.Descriptor instead.
+func (*AgentLoad) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *AgentLoad) GetInflightTasks() int32 {
+	if x != nil {
+		return x.InflightTasks
+	}
+	return 0
+}
+
+func (x *AgentLoad) GetMaxConcurrent() int32 {
+	if x != nil {
+		return x.MaxConcurrent
+	}
+	return 0
+}
+
+func (x *AgentLoad) GetTransportMode() string {
+	if x != nil {
+		return x.TransportMode
+	}
+	return ""
+}
+
+func (x *AgentLoad) GetSpoolDepth() int32 {
+	if x != nil {
+		return x.SpoolDepth
+	}
+	return 0
+}
+
+func (x *AgentLoad) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+type ReportStatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportStatusResponse) Reset() {
+	*x = ReportStatusResponse{}
+	mi := &file_client_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportStatusResponse) ProtoMessage() {}
+
+func (x *ReportStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportStatusResponse.ProtoReflect.Descriptor instead.
+func (*ReportStatusResponse) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ReportStatusResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *ReportStatusResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+var File_client_proto protoreflect.FileDescriptor
+
+const file_client_proto_rawDesc = "" +
+	"\n" +
+	"\fclient.proto\x12\aspiders\"a\n" +
+	"\vTaskRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x12\n" +
+	"\x04flag\x18\x02 \x01(\tR\x04flag\x12(\n" +
+	"\x05agent\x18\x03 \x01(\v2\x12.spiders.AgentInfoR\x05agent\"\xd2\x03\n" +
+	"\vCrawlerTask\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x10\n" +
+	"\x03tag\x18\x02 \x01(\tR\x03tag\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\x12!\n" +
+	"\fbilling_type\x18\x04 \x01(\tR\vbillingType\x12\x1b\n" +
+	"\tcrawl_num\x18\x05 \x01(\x05R\bcrawlNum\x12!\n" +
+	"\fextra_header\x18\x06 \x01(\tR\vextraHeader\x12\x1d\n" +
+	"\n" +
+	"req_method\x18\a \x01(\tR\treqMethod\x12\x1a\n" +
+	"\bdeadline\x18\b \x01(\x03R\bdeadline\x12!\n" +
+	"\fmax_attempts\x18\t \x01(\x05R\vmaxAttempts\x12(\n" +
+	"\x10retry_backoff_ms\x18\n" +
+	" \x01(\x05R\x0eretryBackoffMs\x12\x1c\n" +
+	"\ttimestamp\x18\v \x01(\x03R\ttimestamp\x12\x14\n" +
+	"\x05nonce\x18\f \x01(\tR\x05nonce\x12\x1c\n" +
+	"\tsignature\x18\r \x01(\tR\tsignature\x12\x19\n" +
+	"\btrace_id\x18\x0e \x01(\tR\atraceId\x12\x1a\n" +
+	"\bpriority\x18\x0f \x01(\x05R\bpriority\x12\x15\n" +
+	"\x06ttl_ms\x18\x10 \x01(\x03R\x05ttlMs\"\xe5\x04\n" +
+	"\rCrawlerResult\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x10\n" +
+	"\x03tag\x18\x02 \x01(\tR\x03tag\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\x12!\n" +
+	"\fbilling_type\x18\x04 \x01(\tR\vbillingType\x12\x1b\n" +
+	"\tcrawl_num\x18\x05 \x01(\x05R\bcrawlNum\x12\x18\n" +
+	"\aruntime\x18\x06 \x01(\x05R\aruntime\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\a \x01(\tR\tstartTime\x12\x18\n" +
+	"\asuccess\x18\b \x01(\bR\asuccess\x12\x1d\n" +
+	"\n" +
+	"req_method\x18\t \x01(\tR\treqMethod\x12\x19\n" +
+	"\bweb_data\x18\n" +
+	" \x01(\tR\awebData\x12#\n" +
+	"\rattempt_count\x18\v \x01(\x05R\fattemptCount\x121\n" +
+	"\battempts\x18\f \x03(\v2\x15.spiders.CrawlAttemptR\battempts\x12\x1f\n" +
+	"\verror_class\x18\r \x01(\tR\n" +
+	"errorClass\x12\x1c\n" +
+	"\ttimestamp\x18\x0e \x01(\x03R\ttimestamp\x12\x14\n" +
+	"\x05nonce\x18\x0f \x01(\tR\x05nonce\x12\x1c\n" +
+	"\tsignature\x18\x10 \x01(\tR\tsignature\x12(\n" +
+	"\x05agent\x18\x11 \x01(\v2\x12.spiders.AgentInfoR\x05agent\x12\x19\n" +
+	"\btrace_id\x18\x12 \x01(\tR\atraceId\x12\x1f\n" +
+	"\vthrottle_ms\x18\x13 \x01(\x03R\n" +
+	"throttleMs\x12\x1c\n" +
+	"\tcoalesced\x18\x14 \x01(\bR\tcoalesced\"\xa8\x01\n" +
+	"\fCrawlAttempt\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\x12\x1f\n" +
+	"\verror_class\x18\x02 \x01(\tR\n" +
+	"errorClass\x12\x1f\n" +
+	"\vstatus_code\x18\x03 \x01(\x05R\n" +
+	"statusCode\x12\x1f\n" +
+	"\vduration_ms\x18\x04 \x01(\x03R\n" +
+	"durationMs\x12\x1f\n" +
+	"\vthrottle_ms\x18\x05 \x01(\x03R\n" +
+	"throttleMs\"D\n" +
+	"\x0eHandleResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"M\n" +
+	"\x0eControlRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12%\n" +
+	"\x0eenable_spiders\x18\x02 \x01(\bR\renableSpiders\"C\n" +
+	"\x0fControlResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\bR\x06status\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"%\n" +
+	"\rStatusRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\"B\n" +
+	"\x0eStatusResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\bR\x06status\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"y\n" +
+	"\tAgentInfo\x12\x19\n" +
+	"\bagent_id\x18\x01 \x01(\tR\aagentId\x12\x1a\n" +
+	"\bhostname\x18\x02 \x01(\tR\bhostname\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x12\x1b\n" +
+	"\tpublic_ip\x18\x04 \x01(\tR\bpublicIp\"Q\n" +
+	"\x0fRegisterRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12(\n" +
+	"\x05agent\x18\x02 \x01(\v2\x12.spiders.AgentInfoR\x05agent\"g\n" +
+	"\x10RegisterResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1f\n" +
+	"\vagent_token\x18\x03 \x01(\tR\n" +
+	"agentToken\"\xed\x01\n" +
+	"\fStatusReport\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12(\n" +
+	"\x05agent\x18\x02 \x01(\v2\x12.spiders.AgentInfoR\x05agent\x12\x1c\n" +
+	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\x12&\n" +
+	"\x04host\x18\x04 \x01(\v2\x12.spiders.HostStatsR\x04host\x12/\n" +
+	"\aruntime\x18\x05 \x01(\v2\x15.spiders.RuntimeStatsR\aruntime\x12&\n" +
+	"\x04load\x18\x06 \x01(\v2\x12.spiders.AgentLoadR\x04load\"\x91\x03\n" +
+	"\tHostStats\x12\x17\n" +
+	"\anum_cpu\x18\x01 \x01(\x05R\x06numCpu\x12\x14\n" +
+	"\x05load1\x18\x02 \x01(\x01R\x05load1\x12\x14\n" +
+	"\x05load5\x18\x03 \x01(\x01R\x05load5\x12\x16\n" +
+	"\x06load15\x18\x04 \x01(\x01R\x06load15\x12\x1f\n" +
+	"\vcpu_percent\x18\x05 \x01(\x01R\n" +
+	"cpuPercent\x12&\n" +
+	"\x0fmem_total_bytes\x18\x06 \x01(\x04R\rmemTotalBytes\x12.\n" +
+	"\x13mem_available_bytes\x18\a \x01(\x04R\x11memAvailableBytes\x12.\n" +
+	"\x14net_rx_bytes_per_sec\x18\b \x01(\x01R\x10netRxBytesPerSec\x12.\n" +
+	"\x14net_tx_bytes_per_sec\x18\t \x01(\x01R\x10netTxBytesPerSec\x12'\n" +
+	"\x0ftcp_connections\x18\n" +
+	" \x01(\x05R\x0etcpConnections\x12%\n" +
+	"\x0euptime_seconds\x18\v \x01(\x03R\ruptimeSeconds\"\x9a\x02\n" +
+	"\fRuntimeStats\x12\x1d\n" +
+	"\n" +
+	"go_version\x18\x01 \x01(\tR\tgoVersion\x12#\n" +
+	"\rbuild_version\x18\x02 \x01(\tR\fbuildVersion\x12!\n" +
+	"\fvcs_revision\x18\x03 \x01(\tR\vvcsRevision\x12\x1e\n" +
+	"\n" +
+	"goroutines\x18\x04 \x01(\x05R\n" +
+	"goroutines\x12(\n" +
+	"\x10heap_alloc_bytes\x18\x05 \x01(\x04R\x0eheapAllocBytes\x12\x1b\n" +
+	"\tsys_bytes\x18\x06 \x01(\x04R\bsysBytes\x12\x15\n" +
+	"\x06num_gc\x18\a \x01(\rR\x05numGc\x12%\n" +
+	"\x0euptime_seconds\x18\b \x01(\x03R\ruptimeSeconds\"\xb9\x01\n" +
+	"\tAgentLoad\x12%\n" +
+	"\x0einflight_tasks\x18\x01 \x01(\x05R\rinflightTasks\x12%\n" +
+	"\x0emax_concurrent\x18\x02 \x01(\x05R\rmaxConcurrent\x12%\n" +
+	"\x0etransport_mode\x18\x03 \x01(\tR\rtransportMode\x12\x1f\n" +
+	"\vspool_depth\x18\x04 \x01(\x05R\n" +
+	"spoolDepth\x12\x16\n" +
+	"\x06paused\x18\x05 \x01(\bR\x06paused\"J\n" +
+	"\x14ReportStatusResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage2\xa2\x03\n" +
 	"\rSpiderService\x127\n" +
 	"\aGetTask\x12\x14.spiders.TaskRequest\x1a\x14.spiders.CrawlerTask\"\x00\x12?\n" +
 	"\n" +
 	"HandleTask\x12\x16.spiders.CrawlerResult\x1a\x17.spiders.HandleResponse\"\x00\x12E\n" +
 	"\x0eControlSpiders\x12\x17.spiders.ControlRequest\x1a\x18.spiders.ControlResponse\"\x00\x12E\n" +
-	"\x10GetSpidersStatus\x12\x16.spiders.StatusRequest\x1a\x17.spiders.StatusResponse\"\x00B\tZ\a.;protob\x06proto3"
+	"\x10GetSpidersStatus\x12\x16.spiders.StatusRequest\x1a\x17.spiders.StatusResponse\"\x00\x12A\n" +
+	"\bRegister\x12\x18.spiders.RegisterRequest\x1a\x19.spiders.RegisterResponse\"\x00\x12F\n" +
+	"\fReportStatus\x12\x15.spiders.StatusReport\x1a\x1d.spiders.ReportStatusResponse\"\x00B\tZ\a.;protob\x06proto3"
 
 var (
 	file_client_proto_rawDescOnce sync.Once
@@ -597,31 +1540,52 @@ func file_client_proto_rawDescGZIP() []byte {
 	return file_client_proto_rawDescData
 }
 
-var file_client_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_client_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
 var file_client_proto_goTypes = []any{
-	(*TaskRequest)(nil),     // 0: spiders.TaskRequest
-	(*CrawlerTask)(nil),     // 1: spiders.CrawlerTask
-	(*CrawlerResult)(nil),   // 2: spiders.CrawlerResult
-	(*HandleResponse)(nil),  // 3: spiders.HandleResponse
-	(*ControlRequest)(nil),  // 4: spiders.ControlRequest
-	(*ControlResponse)(nil), // 5: spiders.ControlResponse
-	(*StatusRequest)(nil),   // 6: spiders.StatusRequest
-	(*StatusResponse)(nil),  // 7: spiders.StatusResponse
+	(*TaskRequest)(nil),          // 0: spiders.TaskRequest
+	(*CrawlerTask)(nil),          // 1: spiders.CrawlerTask
+	(*CrawlerResult)(nil),        // 2: spiders.CrawlerResult
+	(*CrawlAttempt)(nil),         // 3: spiders.CrawlAttempt
+	(*HandleResponse)(nil),       // 4: spiders.HandleResponse
+	(*ControlRequest)(nil),       // 5: spiders.ControlRequest
+	(*ControlResponse)(nil),      // 6: spiders.ControlResponse
+	(*StatusRequest)(nil),        // 7: spiders.StatusRequest
+	(*StatusResponse)(nil),       // 8: spiders.StatusResponse
+	(*AgentInfo)(nil),            // 9: spiders.AgentInfo
+	(*RegisterRequest)(nil),      // 10: spiders.RegisterRequest
+	(*RegisterResponse)(nil),     // 11: spiders.RegisterResponse
+	(*StatusReport)(nil),         // 12: spiders.StatusReport
+	(*HostStats)(nil),            // 13: spiders.HostStats
+	(*RuntimeStats)(nil),         // 14: spiders.RuntimeStats
+	(*AgentLoad)(nil),            // 15: spiders.AgentLoad
+	(*ReportStatusResponse)(nil), // 16: spiders.ReportStatusResponse
 }
 var file_client_proto_depIdxs = []int32{
-	0, // 0: spiders.SpiderService.GetTask:input_type -> spiders.TaskRequest
-	2, // 1: spiders.SpiderService.HandleTask:input_type -> spiders.CrawlerResult
-	4, // 2: spiders.SpiderService.ControlSpiders:input_type -> spiders.ControlRequest
-	6, // 3: spiders.SpiderService.GetSpidersStatus:input_type -> spiders.StatusRequest
-	1, // 4: spiders.SpiderService.GetTask:output_type -> spiders.CrawlerTask
-	3, // 5: spiders.SpiderService.HandleTask:output_type -> spiders.HandleResponse
-	5, // 6: spiders.SpiderService.ControlSpiders:output_type -> spiders.ControlResponse
-	7, // 7: spiders.SpiderService.GetSpidersStatus:output_type -> spiders.StatusResponse
-	4, // [4:8] is the sub-list for method output_type
-	0, // [0:4] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	9,  // 0: spiders.TaskRequest.agent:type_name -> spiders.AgentInfo
+	3,  // 1: spiders.CrawlerResult.attempts:type_name -> spiders.CrawlAttempt
+	9,  // 2: spiders.CrawlerResult.agent:type_name -> spiders.AgentInfo
+	9,  // 3: spiders.RegisterRequest.agent:type_name -> spiders.AgentInfo
+	9,  // 4: spiders.StatusReport.agent:type_name -> spiders.AgentInfo
+	13, // 5: spiders.StatusReport.host:type_name -> spiders.HostStats
+	14, // 6: spiders.StatusReport.runtime:type_name -> spiders.RuntimeStats
+	15, // 7: spiders.StatusReport.load:type_name -> spiders.AgentLoad
+	0,  // 8: spiders.SpiderService.GetTask:input_type -> spiders.TaskRequest
+	2,  // 9: spiders.SpiderService.HandleTask:input_type -> spiders.CrawlerResult
+	5,  // 10: spiders.SpiderService.ControlSpiders:input_type -> spiders.ControlRequest
+	7,  // 11: spiders.SpiderService.GetSpidersStatus:input_type -> spiders.StatusRequest
+	10, // 12: spiders.SpiderService.Register:input_type -> spiders.RegisterRequest
+	12, // 13: spiders.SpiderService.ReportStatus:input_type -> spiders.StatusReport
+	1,  // 14: spiders.SpiderService.GetTask:output_type -> spiders.CrawlerTask
+	4,  // 15: spiders.SpiderService.HandleTask:output_type -> spiders.HandleResponse
+	6,  // 16: spiders.SpiderService.ControlSpiders:output_type -> spiders.ControlResponse
+	8,  // 17: spiders.SpiderService.GetSpidersStatus:output_type -> spiders.StatusResponse
+	11, // 18: spiders.SpiderService.Register:output_type -> spiders.RegisterResponse
+	16, // 19: spiders.SpiderService.ReportStatus:output_type -> spiders.ReportStatusResponse
+	14, // [14:20] is the sub-list for method output_type
+	8,  // [8:14] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_client_proto_init() }
@@ -635,7 +1599,7 @@ func file_client_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_client_proto_rawDesc), len(file_client_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   17,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -23,6 +23,8 @@ const (
 	SpiderService_HandleTask_FullMethodName       = "/spiders.SpiderService/HandleTask"
 	SpiderService_ControlSpiders_FullMethodName   = "/spiders.SpiderService/ControlSpiders"
 	SpiderService_GetSpidersStatus_FullMethodName = "/spiders.SpiderService/GetSpidersStatus"
+	SpiderService_Register_FullMethodName         = "/spiders.SpiderService/Register"
+	SpiderService_ReportStatus_FullMethodName     = "/spiders.SpiderService/ReportStatus"
 )
 
 // SpiderServiceClient is the client API for SpiderService service.
@@ -33,6 +35,8 @@ type SpiderServiceClient interface {
 	HandleTask(ctx context.Context, in *CrawlerResult, opts ...grpc.CallOption) (*HandleResponse, error)
 	ControlSpiders(ctx context.Context, in *ControlRequest, opts ...grpc.CallOption) (*ControlResponse, error)
 	GetSpidersStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	ReportStatus(ctx context.Context, in *StatusReport, opts ...grpc.CallOption) (*ReportStatusResponse, error)
 }
 
 type spiderServiceClient struct {
@@ -83,6 +87,26 @@ func (c *spiderServiceClient) GetSpidersStatus(ctx context.Context, in *StatusRe
 	return out, nil
 }
 
+func (c *spiderServiceClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, SpiderService_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spiderServiceClient) ReportStatus(ctx context.Context, in *StatusReport, opts ...grpc.CallOption) (*ReportStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportStatusResponse)
+	err := c.cc.Invoke(ctx, SpiderService_ReportStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SpiderServiceServer is the server API for SpiderService service.
 // All implementations must embed UnimplementedSpiderServiceServer
 // for forward compatibility.
@@ -91,6 +115,8 @@ type SpiderServiceServer interface {
 	HandleTask(context.Context, *CrawlerResult) (*HandleResponse, error)
 	ControlSpiders(context.Context, *ControlRequest) (*ControlResponse, error)
 	GetSpidersStatus(context.Context, *StatusRequest) (*StatusResponse, error)
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	ReportStatus(context.Context, *StatusReport) (*ReportStatusResponse, error)
 	mustEmbedUnimplementedSpiderServiceServer()
 }
 
@@ -113,6 +139,12 @@ func (UnimplementedSpiderServiceServer) ControlSpiders(context.Context, *Control
 func (UnimplementedSpiderServiceServer) GetSpidersStatus(context.Context, *StatusRequest) (*StatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetSpidersStatus not implemented")
 }
+func (UnimplementedSpiderServiceServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedSpiderServiceServer) ReportStatus(context.Context, *StatusReport) (*ReportStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportStatus not implemented")
+}
 func (UnimplementedSpiderServiceServer) mustEmbedUnimplementedSpiderServiceServer() {}
 func (UnimplementedSpiderServiceServer) testEmbeddedByValue()                       {}
 
@@ -206,6 +238,42 @@ func _SpiderService_GetSpidersStatus_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SpiderService_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpiderServiceServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SpiderService_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpiderServiceServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SpiderService_ReportStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpiderServiceServer).ReportStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SpiderService_ReportStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpiderServiceServer).ReportStatus(ctx, req.(*StatusReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // SpiderService_ServiceDesc is the grpc.ServiceDesc for SpiderService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -229,6 +297,14 @@ var SpiderService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetSpidersStatus",
 			Handler:    _SpiderService_GetSpidersStatus_Handler,
 		},
+		{
+			MethodName: "Register",
+			Handler:    _SpiderService_Register_Handler,
+		},
+		{
+			MethodName: "ReportStatus",
+			Handler:    _SpiderService_ReportStatus_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "client.proto",
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	pb "agent/proto"
+)
+
+// queuedTask 本地队列中等待执行的任务
+type queuedTask struct {
+	ctx      context.Context
+	task     *pb.CrawlerTask
+	received time.Time
+	seq      uint64 // 优先级相同时先领取的先执行
+}
+
+// expiresAt 任务过期的时间：主控的截止时间和领取后的 TTL 中较早的，都未设置时为零值
+func (q *queuedTask) expiresAt() time.Time {
+	var expires time.Time
+	if q.task.Deadline > 0 {
+		expires = time.Unix(q.task.Deadline, 0)
+	}
+	if q.task.TtlMs > 0 {
+		ttl := q.received.Add(time.Duration(q.task.TtlMs) * time.Millisecond)
+		if expires.IsZero() || ttl.Before(expires) {
+			expires = ttl
+		}
+	}
+	return expires
+}
+
+// taskHeap 按优先级从高到低、同优先级按领取顺序排列
+type taskHeap []*queuedTask
+
+func (h taskHeap) Len() int { return len(h) }
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].task.Priority != h[j].task.Priority {
+		return h[i].task.Priority > h[j].task.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h taskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *taskHeap) Push(x any)   { *h = append(*h, x.(*queuedTask)) }
+func (h *taskHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// taskQueue 有容量上限的本地优先级队列，由拉取循环写入、调度循环取出
+type taskQueue struct {
+	capacity int
+
+	mu    sync.Mutex
+	items taskHeap
+	seq   uint64
+	ready chan struct{} // 有新任务
+	space chan struct{} // 有空位
+}
+
+func newTaskQueue(capacity int) *taskQueue {
+	return &taskQueue{
+		capacity: max(capacity, 1),
+		ready:    make(chan struct{}, 1),
+		space:    make(chan struct{}, 1),
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Len 队列中的任务数
+func (q *taskQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// push 加入任务，调用方应先通过 waitSpace 等待空位
+func (q *taskQueue) push(ctx context.Context, task *pb.CrawlerTask) {
+	q.mu.Lock()
+	q.seq++
+	heap.Push(&q.items, &queuedTask{ctx: ctx, task: task, received: time.Now(), seq: q.seq})
+	q.mu.Unlock()
+	notify(q.ready)
+}
+
+// waitSpace 等待队列有空位，超过 timeout 或 ctx 结束时返回 false
+func (q *taskQueue) waitSpace(ctx context.Context, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		q.mu.Lock()
+		full := len(q.items) >= q.capacity
+		q.mu.Unlock()
+		if !full {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return false
+		case <-q.space:
+		}
+	}
+}
+
+// waitReady 等待队列中有任务，ctx 结束时返回 false
+func (q *taskQueue) waitReady(ctx context.Context) bool {
+	for {
+		if q.Len() > 0 {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-q.ready:
+		}
+	}
+}
+
+// pop 取出优先级最高的任务，队列为空时返回 nil
+func (q *taskQueue) pop() *queuedTask {
+	q.mu.Lock()
+	if len(q.items) == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	item := heap.Pop(&q.items).(*queuedTask)
+	q.mu.Unlock()
+	notify(q.space)
+	return item
+}
+
+// drain 取出剩余的全部任务
+func (q *taskQueue) drain() []*queuedTask {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	return items
+}
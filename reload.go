@@ -0,0 +1,219 @@
+package main
+
+import (
+	"agent/controller"
+	"agent/logging"
+	"context"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// configPollInterval 检查配置文件是否修改的间隔
+const configPollInterval = 5 * time.Second
+
+// reloadKind 参数变更的生效方式
+type reloadKind int
+
+const (
+	reloadLive      reloadKind = iota // 立即生效
+	reloadReconnect                   // 等在途任务完成后重建主控连接
+	reloadRestart                     // 需重启进程
+)
+
+func (k reloadKind) String() string {
+	switch k {
+	case reloadLive:
+		return "立即生效"
+	case reloadReconnect:
+		return "重建连接后生效"
+	default:
+		return "需重启生效"
+	}
+}
+
+// liveSettings 可直接在运行中修改的参数，未列出的主控相关参数需要重建连接
+var liveSettings = map[string]bool{
+	"max-concurrent":       true,
+	"min-concurrent":       true,
+	"task-flag":            true,
+	"crawl-timeout":        true,
+	"crawler-profile":      true,
+	"proxy":                true,
+	"host-limits":          true,
+	"max-retry-after":      true,
+	"robots-billing-types": true,
+	"coalesce-window":      true,
+	"log-level":            true,
+	"log-subsystem-levels": true,
+	"shutdown-timeout":     true,
+	"retry-max-attempts":   true,
+	"retry-backoff":        true,
+	"retry-max-backoff":    true,
+	"retry-jitter":         true,
+	"retry-on":             true,
+}
+
+// restartSettings 只在启动时读取的参数
+var restartSettings = map[string]bool{
+	"config":             true,
+	"log-format":         true,
+	"status-interval":    true,
+	"concurrency":        true,
+	"queue-size":         true,
+	"report-interval":    true,
+	"spool-dir":          true,
+	"spool-max-entries":  true,
+	"spool-max-bytes":    true,
+	"spool-max-age":      true,
+	"identity-file":      true,
+	"public-ip-url":      true,
+	"admin-addr":         true,
+	"ready-dns-name":     true,
+	"trace-exporter":     true,
+	"trace-endpoint":     true,
+	"trace-sample-ratio": true,
+}
+
+// secretSettings 日志中需要遮蔽的参数
+var secretSettings = map[string]bool{
+	"token":       true,
+	"hmac-secret": true,
+}
+
+// settingChange 一项参数的变化
+type settingChange struct {
+	name string
+	from string
+	to   string
+	kind reloadKind
+}
+
+// diffSettings 比较两份参数，按参数名排序返回变化项
+func diffSettings(old, next *settings) []settingChange {
+	var changes []settingChange
+	for name, to := range next.values {
+		from := old.values[name]
+		if from == to {
+			continue
+		}
+		kind := reloadReconnect
+		if liveSettings[name] {
+			kind = reloadLive
+		} else if restartSettings[name] {
+			kind = reloadRestart
+		}
+		if secretSettings[name] {
+			from, to = logging.MaskToken(from), logging.MaskToken(to)
+		}
+		changes = append(changes, settingChange{name: name, from: from, to: to, kind: kind})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].name < changes[j].name })
+	return changes
+}
+
+// watchReload 收到 SIGHUP 或配置文件被修改时发出重新加载请求，值为触发原因
+func watchReload(ctx context.Context, path string) <-chan string {
+	reload := make(chan string, 1)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	request := func(reason string) {
+		select {
+		case reload <- reason:
+		default:
+		}
+	}
+	go func() {
+		defer signal.Stop(hup)
+		var lastMod time.Time
+		var lastSize int64
+		if info, err := os.Stat(path); path != "" && err == nil {
+			lastMod, lastSize = info.ModTime(), info.Size()
+		}
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				request("SIGHUP")
+			case <-ticker.C:
+				if path == "" {
+					continue
+				}
+				info, err := os.Stat(path)
+				if err != nil {
+					continue
+				}
+				if !info.ModTime().Equal(lastMod) || info.Size() != lastSize {
+					lastMod, lastSize = info.ModTime(), info.Size()
+					request("配置文件已修改")
+				}
+			}
+		}
+	}()
+	return reload
+}
+
+// reloadSettings 重新读取参数并应用，失败时保留当前参数
+func reloadSettings(client *SpiderClient, current *settings, reason string) *settings {
+	logger.Info("重新加载配置", "reason", reason)
+	next, err := loadSettings(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		logger.Warn("重新加载配置失败，继续使用当前配置", logging.KeyError, err)
+		return current
+	}
+	changes := diffSettings(current, next)
+	if len(changes) == 0 {
+		logger.Info("配置未变化")
+		return current
+	}
+	reconnect := false
+	for _, change := range changes {
+		logger.Info("配置变更", "name", change.name, "from", change.from, "to", change.to, "apply", change.kind.String())
+		reconnect = reconnect || change.kind == reloadReconnect
+	}
+
+	updateConcurrency(client, next)
+	client.coalescer.setWindow(next.coalesceWindow)
+	client.crawler.SetRetryPolicy(next.retry)
+	if err := client.crawler.SetOptions(next.crawlOpts); err != nil {
+		logger.Warn("更新爬虫参数失败", logging.KeyError, err)
+	}
+	applyLogLevels(next)
+
+	if reconnect {
+		// 在途任务结束前暂停拉取新任务，超时后剩余任务继续使用旧连接直至完成
+		logger.Info("等待在途任务完成后重建主控连接")
+		if !client.Drain(next.shutdownTimeout) {
+			logger.Warn("等待超时，剩余任务继续使用旧连接", "inflight", client.inflight.count.Load())
+		}
+		opts := next.controllerOptions()
+		if next.hmacSecret != current.hmacSecret || next.hmacWindow != current.hmacWindow {
+			if next.hmacSecret != "" {
+				opts.Signer = controller.NewSigner(next.hmacSecret, next.hmacWindow)
+			}
+		} else {
+			opts.Signer = client.currentOptions().Signer
+		}
+		if err := client.UpdateControllerOptions(opts); err != nil {
+			logger.Warn("重建主控连接失败，继续使用当前连接", logging.KeyError, err)
+			next.keepControllerValues(current)
+		}
+	} else {
+		client.SetTaskFlag(next.taskFlag)
+	}
+	return next
+}
+
+// keepControllerValues 重建连接失败时保留旧的主控参数取值，下次重新加载时会再次尝试
+func (s *settings) keepControllerValues(from *settings) {
+	for name := range s.values {
+		if !liveSettings[name] && !restartSettings[name] {
+			s.values[name] = from.values[name]
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"agent/config"
+	"agent/controller"
+	"agent/crawler"
+	"agent/logging"
+	"agent/spool"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// settings 启动参数，来自命令行、环境变量和配置文件
+type settings struct {
+	configFile string
+
+	token    string
+	host     string
+	grpcPort string
+	apiPort  string
+	apiURL   string
+	srvName  string
+	lbPolicy string
+	taskFlag string
+
+	statusInterval  time.Duration
+	reportInterval  time.Duration
+	shutdownTimeout time.Duration
+	concurrency     string
+	minConcurrent   int
+	maxConcurrent   int
+	queueSize       int
+
+	spoolDir       string
+	spoolOpts      spool.Options
+	crawlOpts      crawler.Options
+	proxies        string
+	hostLimits     string
+	robots         string
+	coalesceWindow time.Duration
+	retry          crawler.RetryPolicy
+	retryOn        string
+	tlsOptions     controller.TLSOptions
+	tlsPins        string
+	grpcOpts       controller.GRPCOptions
+
+	hmacSecret string
+	hmacWindow time.Duration
+
+	identityFile string
+	publicIPURL  string
+
+	logLevel           string
+	logFormat          string
+	logSubsystemLevels string
+	level              slog.Level
+	subsystemLevels    map[string]slog.Level
+
+	adminAddr    string
+	readyDNSName string
+
+	traceExporter    string
+	traceEndpoint    string
+	traceSampleRatio float64
+
+	values map[string]string // 每个参数的最终取值，热加载时用于比较
+}
+
+// newFlagSet 定义所有参数，默认值写入 s
+func newFlagSet(s *settings) *flag.FlagSet {
+	fs := flag.NewFlagSet("ecsagent", flag.ContinueOnError)
+	s.retry = crawler.DefaultRetryPolicy()
+	s.grpcOpts = controller.DefaultGRPCOptions()
+	s.crawlOpts = crawler.DefaultOptions()
+
+	fs.StringVar(&s.configFile, "config", "", "JSON 配置文件路径，命令行参数和环境变量优先于配置文件")
+	fs.StringVar(&s.token, "token", "", "爬虫校验的Token")
+	fs.StringVar(&s.host, "host", "", "主控的IP地址，多个主控用逗号分隔")
+	fs.StringVar(&s.grpcPort, "grpc-port", "", "主控的gRPC通信端口")
+	fs.StringVar(&s.apiPort, "api-port", "", "主控的API通信端口")
+	fs.StringVar(&s.apiURL, "api-url", "", "主控API的完整地址，如 https://example.com/monitor，可替代 -host/-api-port，多个用逗号分隔")
+	fs.StringVar(&s.srvName, "controller-srv", "", "主控gRPC地址的DNS SRV记录，如 _grpc._tcp.example.com，可替代 -host/-grpc-port")
+	fs.StringVar(&s.lbPolicy, "lb-policy", "pick_first", "多个gRPC主控的负载均衡策略 (pick_first: 按顺序主备, round_robin: 轮询)")
+	fs.DurationVar(&s.grpcOpts.KeepaliveTime, "grpc-keepalive-time", s.grpcOpts.KeepaliveTime, "gRPC连接空闲多久后发送ping，0为不发送")
+	fs.DurationVar(&s.grpcOpts.KeepaliveTimeout, "grpc-keepalive-timeout", s.grpcOpts.KeepaliveTimeout, "gRPC ping 无响应多久后断开重连")
+	fs.BoolVar(&s.grpcOpts.PermitWithoutStream, "grpc-keepalive-idle", false, "没有进行中的调用时也发送ping，需主控允许")
+	fs.IntVar(&s.grpcOpts.MaxSendMsgSize, "grpc-max-send-msg", s.grpcOpts.MaxSendMsgSize, "gRPC单条消息发送上限 (字节)")
+	fs.IntVar(&s.grpcOpts.MaxRecvMsgSize, "grpc-max-recv-msg", s.grpcOpts.MaxRecvMsgSize, "gRPC单条消息接收上限 (字节)")
+	fs.IntVar(&s.grpcOpts.RetryMaxAttempts, "grpc-retry-max-attempts", s.grpcOpts.RetryMaxAttempts, "GetTask 遇到 UNAVAILABLE 时最多尝试次数 (2~5)，小于2不重试")
+	fs.DurationVar(&s.grpcOpts.RetryInitialBackoff, "grpc-retry-backoff", s.grpcOpts.RetryInitialBackoff, "gRPC第一次重试前的等待时间")
+	fs.DurationVar(&s.grpcOpts.RetryMaxBackoff, "grpc-retry-max-backoff", s.grpcOpts.RetryMaxBackoff, "gRPC重试等待时间上限")
+	fs.StringVar(&s.taskFlag, "task-flag", "", "任务类型标识 (可选: cf5s, dynamic, 默认为空)")
+	fs.DurationVar(&s.statusInterval, "status-interval", 30*time.Second, "轮询主控爬虫启停状态的间隔")
+	fs.DurationVar(&s.reportInterval, "report-interval", time.Minute, "向主控上报主机资源和任务负载的间隔，0 为不上报")
+	fs.DurationVar(&s.shutdownTimeout, "shutdown-timeout", 30*time.Second, "退出时等待在途任务完成的最长时间")
+	fs.StringVar(&s.concurrency, "concurrency", concurrencyAdaptive, "并发控制方式 (adaptive: 按延迟、错误率和本机资源自动调整, fixed: 固定为 -max-concurrent)")
+	fs.IntVar(&s.minConcurrent, "min-concurrent", 1, "自适应并发的下限")
+	fs.IntVar(&s.maxConcurrent, "max-concurrent", maxConcurrentTasks, "同时执行的最大任务数，自适应并发时为上限")
+	fs.IntVar(&s.queueSize, "queue-size", 2*maxConcurrentTasks, "本地任务队列的容量，队列满时暂停拉取，按任务优先级执行")
+	fs.DurationVar(&s.crawlOpts.Timeout, "crawl-timeout", s.crawlOpts.Timeout, "单次爬取请求的超时")
+	fs.StringVar(&s.crawlOpts.Profile, "crawler-profile", s.crawlOpts.Profile, "模拟的浏览器指纹 (chrome, firefox, safari, none)")
+	fs.StringVar(&s.proxies, "proxy", "", "爬取使用的代理，逗号分隔时按任务轮流使用 (http, https, socks5)")
+	fs.StringVar(&s.hostLimits, "host-limits", joinHostLimits(s.crawlOpts.HostLimits),
+		"按域名限制爬取频率和并发，逗号分隔，每项为 域名=每秒请求数/突发数/并发数，域名可为 example.com、*.example.com 或 *，0 为不限制")
+	fs.StringVar(&s.robots, "robots-billing-types", "", "需要遵守 robots.txt 的任务计费类型，逗号分隔，* 为全部，为空时都不遵守")
+	fs.DurationVar(&s.coalesceWindow, "coalesce-window", 0, "合并相同请求（方法、URL、附加请求头）的任务，并在该时间内复用成功的结果，0 为不合并")
+	fs.DurationVar(&s.crawlOpts.MaxRetryAfter, "max-retry-after", s.crawlOpts.MaxRetryAfter, "遇到 429/503 的 Retry-After 时暂停访问该域名的最长时间，0 为不遵守")
+	fs.StringVar(&s.spoolDir, "spool-dir", "/var/lib/ecsagent/spool", "提交失败结果的本地缓存目录，为空时不缓存")
+	fs.IntVar(&s.spoolOpts.MaxEntries, "spool-max-entries", 10000, "本地缓存的最大条数")
+	fs.Int64Var(&s.spoolOpts.MaxBytes, "spool-max-bytes", 512<<20, "本地缓存的最大字节数")
+	fs.DurationVar(&s.spoolOpts.MaxAge, "spool-max-age", 24*time.Hour, "本地缓存结果的最长保留时间")
+	fs.IntVar(&s.retry.MaxAttempts, "retry-max-attempts", s.retry.MaxAttempts, "单个任务最多请求次数，包含第一次")
+	fs.DurationVar(&s.retry.InitialBackoff, "retry-backoff", s.retry.InitialBackoff, "第一次重试前的等待时间，之后每次翻倍")
+	fs.DurationVar(&s.retry.MaxBackoff, "retry-max-backoff", s.retry.MaxBackoff, "重试等待时间上限")
+	fs.Float64Var(&s.retry.Jitter, "retry-jitter", s.retry.Jitter, "重试等待时间的随机抖动比例 (0~1)")
+	fs.StringVar(&s.retryOn, "retry-on", strings.Join(s.retry.RetryOn, ","),
+		"可重试的错误分类，逗号分隔 (timeout, conn_reset, conn_refused, dns, tls, network, http_gateway, http_status)")
+	fs.BoolVar(&s.tlsOptions.Insecure, "insecure", false, "与主控明文通信 (不使用TLS)，仅限内网或调试")
+	fs.StringVar(&s.tlsOptions.CAFile, "tls-ca", "", "校验主控证书的CA文件，默认使用系统根证书")
+	fs.StringVar(&s.tlsOptions.CertFile, "tls-cert", "", "双向TLS的客户端证书文件")
+	fs.StringVar(&s.tlsOptions.KeyFile, "tls-key", "", "双向TLS的客户端私钥文件")
+	fs.StringVar(&s.tlsOptions.ServerName, "tls-server-name", "", "校验主控证书时使用的服务器名，默认为 -host")
+	fs.StringVar(&s.tlsPins, "tls-pin", "", "主控证书公钥的SHA256指纹，逗号分隔，hex 或 base64")
+	fs.StringVar(&s.hmacSecret, "hmac-secret", "", "与主控共享的签名密钥，设置后校验任务签名并对结果签名")
+	fs.DurationVar(&s.hmacWindow, "hmac-window", 5*time.Minute, "签名时间戳允许的偏差及防重放窗口")
+	fs.StringVar(&s.identityFile, "identity-file", "/var/lib/ecsagent/identity.json", "保存Agent ID和专属凭证的文件")
+	fs.StringVar(&s.publicIPURL, "public-ip-url", "", "查询本机公网IP的地址（如 https://api.ipify.org），为空时不查询")
+	fs.StringVar(&s.logLevel, "log-level", "info", "日志级别 (debug, info, warn, error)")
+	fs.StringVar(&s.logFormat, "log-format", logging.FormatText, "日志格式 (text, json)")
+	fs.StringVar(&s.logSubsystemLevels, "log-subsystem-levels", "", "子系统的日志级别，覆盖 -log-level，如 controller=debug,crawler=warn")
+	fs.StringVar(&s.adminAddr, "admin-addr", "", "管理接口监听地址，如 127.0.0.1:9108，提供 /metrics、/healthz 和 /readyz，为空时不监听")
+	fs.StringVar(&s.readyDNSName, "ready-dns-name", "", "/readyz 检查域名解析时使用的域名，如 example.com，为空时不检查")
+	fs.StringVar(&s.traceExporter, "trace-exporter", "", "链路追踪的导出方式 (otlp: 发送到采集器, stdout: 输出到标准输出)，为空时不追踪")
+	fs.StringVar(&s.traceEndpoint, "trace-endpoint", "http://127.0.0.1:4318", "OTLP/HTTP 采集器地址，span 发送到其 /v1/traces")
+	fs.Float64Var(&s.traceSampleRatio, "trace-sample-ratio", 1, "新链路的采样比例 (0~1)，主控下发的链路按链路ID采样")
+	return fs
+}
+
+// loadSettings 解析命令行参数，再用环境变量和配置文件补充未指定的参数，最后校验
+func loadSettings(args []string, lookupEnv func(string) (string, bool)) (*settings, error) {
+	s := &settings{}
+	fs := newFlagSet(s)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	// 配置文件路径本身也可以来自环境变量
+	if s.configFile == "" {
+		if path, ok := lookupEnv(config.EnvName("config")); ok {
+			s.configFile = path
+		}
+	}
+	var cfg *config.Config
+	if s.configFile != "" {
+		var err error
+		if cfg, err = config.Load(s.configFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.Apply(fs, cfg, lookupEnv); err != nil {
+		return nil, err
+	}
+	s.retry.RetryOn = splitList(s.retryOn)
+	s.tlsOptions.PinSHA256 = splitList(s.tlsPins)
+	s.crawlOpts.Proxies = splitList(s.proxies)
+	s.crawlOpts.RobotsBillingTypes = splitList(s.robots)
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	s.values = map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		s.values[f.Name] = f.Value.String()
+	})
+	return s, nil
+}
+
+// validate 校验参数组合
+func (s *settings) validate() error {
+	switch {
+	case s.srvName != "":
+		if s.token == "" || (s.apiURL == "" && s.apiPort == "") {
+			return fmt.Errorf("使用 -controller-srv 时需要提供 -token 以及 -api-port 或 -api-url")
+		}
+	case s.apiURL == "":
+		if s.token == "" || s.host == "" || s.grpcPort == "" || s.apiPort == "" {
+			return fmt.Errorf("请提供所有必需的参数: -token, -host, -grpc-port, -api-port (或使用 -api-url 替代 -host/-api-port)")
+		}
+	default:
+		if s.token == "" {
+			return fmt.Errorf("请提供必需的参数: -token")
+		}
+		if (s.host == "") != (s.grpcPort == "") {
+			return fmt.Errorf("使用gRPC需要同时提供 -host 和 -grpc-port，仅使用API时两者都留空")
+		}
+	}
+	if s.lbPolicy != "pick_first" && s.lbPolicy != "round_robin" {
+		return fmt.Errorf("无效的 -lb-policy: %s (可选: pick_first, round_robin)", s.lbPolicy)
+	}
+	if s.maxConcurrent < 1 {
+		return fmt.Errorf("-max-concurrent 必须大于0")
+	}
+	if s.queueSize < 1 {
+		return fmt.Errorf("-queue-size 必须大于0")
+	}
+	if s.concurrency != concurrencyAdaptive && s.concurrency != concurrencyFixed {
+		return fmt.Errorf("无效的 -concurrency: %s (可选: adaptive, fixed)", s.concurrency)
+	}
+	if s.minConcurrent < 1 || s.minConcurrent > s.maxConcurrent {
+		return fmt.Errorf("-min-concurrent 必须在 1 和 -max-concurrent 之间")
+	}
+	if s.retry.MaxAttempts < 1 {
+		return fmt.Errorf("-retry-max-attempts 必须大于0")
+	}
+	if s.retry.Jitter < 0 || s.retry.Jitter > 1 {
+		return fmt.Errorf("-retry-jitter 必须在 0~1 之间")
+	}
+	if err := s.grpcOpts.Validate(); err != nil {
+		return err
+	}
+	var err error
+	if s.crawlOpts.HostLimits, err = crawler.ParseHostLimits(splitList(s.hostLimits)); err != nil {
+		return err
+	}
+	if err := s.crawlOpts.Validate(); err != nil {
+		return err
+	}
+	if s.level, err = logging.ParseLevel(s.logLevel); err != nil {
+		return err
+	}
+	if s.subsystemLevels, err = logging.ParseSubsystemLevels(s.logSubsystemLevels); err != nil {
+		return err
+	}
+	if s.logFormat != logging.FormatText && s.logFormat != logging.FormatJSON {
+		return fmt.Errorf("无效的 -log-format: %s (可选: text, json)", s.logFormat)
+	}
+	if s.traceExporter != "" && s.traceExporter != "otlp" && s.traceExporter != "stdout" {
+		return fmt.Errorf("无效的 -trace-exporter: %s (可选: otlp, stdout)", s.traceExporter)
+	}
+	if s.traceSampleRatio < 0 || s.traceSampleRatio > 1 {
+		return fmt.Errorf("-trace-sample-ratio 必须在 0~1 之间")
+	}
+	return nil
+}
+
+// joinHostLimits 域名限制的文本形式，与 crawler.ParseHostLimits 对应
+func joinHostLimits(limits []crawler.HostLimit) string {
+	items := make([]string, len(limits))
+	for i, l := range limits {
+		items[i] = l.String()
+	}
+	return strings.Join(items, ",")
+}
+
+// applyLogLevels 应用全局和各子系统的日志级别
+func applyLogLevels(s *settings) {
+	logging.SetLevel(s.level)
+	logging.SetSubsystemLevels(s.subsystemLevels)
+}
+
+// controllerOptions 主控连接参数
+func (s *settings) controllerOptions() controller.Options {
+	return controller.Options{
+		Token:    s.token,
+		GrpcPort: s.grpcPort,
+		ApiPort:  s.apiPort,
+		TaskFlag: s.taskFlag,
+		TLS:      s.tlsOptions,
+
+		Hosts:       splitList(s.host),
+		SRVName:     s.srvName,
+		LBPolicy:    s.lbPolicy,
+		APIBaseURLs: splitList(s.apiURL),
+		GRPC:        s.grpcOpts,
+	}
+}
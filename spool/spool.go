@@ -0,0 +1,391 @@
+package spool
+
+import (
+	"agent/logging"
+	pb "agent/proto"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var logger = logging.For("spool")
+
+const (
+	logFileName    = "results.log"
+	initialBackoff = 10 * time.Second
+	maxBackoff     = 30 * time.Minute
+)
+
+// ErrPermanent 提交函数返回包装了该错误的 error 时，条目会被直接丢弃而不再重试
+var ErrPermanent = errors.New("结果无法重新提交")
+
+// Options 本地缓存的容量限制，零值表示不限制
+type Options struct {
+	MaxEntries int
+	MaxBytes   int64
+	MaxAge     time.Duration
+}
+
+// Entry 提交失败、等待重放的任务结果
+type Entry struct {
+	Tag       string          `json:"tag"`
+	Result    json.RawMessage `json:"result"`
+	CreatedAt time.Time       `json:"created_at"`
+	Attempts  int             `json:"attempts"`
+	NextRetry time.Time       `json:"next_retry"`
+}
+
+// record 追加写入日志的一条记录
+type record struct {
+	Op    string `json:"op"`
+	Tag   string `json:"tag,omitempty"`
+	Entry *Entry `json:"entry,omitempty"`
+}
+
+// Spool 基于追加写日志的本地结果缓存，按任务 Tag 去重
+type Spool struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	opts    Options
+	entries map[string]*Entry
+	bytes   int64
+	dead    int // 日志中已被覆盖或删除的记录数，超过阈值后压缩
+}
+
+// Open 打开指定目录下的缓存日志，不存在时创建
+func Open(dir string, opts Options) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %v", err)
+	}
+	s := &Spool{
+		path:    filepath.Join(dir, logFileName),
+		opts:    opts,
+		entries: make(map[string]*Entry),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.prune(time.Now())
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 重放日志恢复缓存内容，末尾写坏的记录会被忽略
+func (s *Spool) load() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开缓存日志失败: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			logger.Warn("跳过损坏的缓存记录", logging.KeyError, err)
+			continue
+		}
+		switch r.Op {
+		case "put":
+			if r.Entry != nil {
+				s.set(r.Entry)
+			}
+		case "del":
+			s.unset(r.Tag)
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *Spool) set(e *Entry) {
+	s.unset(e.Tag)
+	s.entries[e.Tag] = e
+	s.bytes += int64(len(e.Result))
+}
+
+func (s *Spool) unset(tag string) {
+	if old, ok := s.entries[tag]; ok {
+		s.bytes -= int64(len(old.Result))
+		delete(s.entries, tag)
+	}
+}
+
+// append 追加一条记录并落盘
+func (s *Spool) append(r record) error {
+	if s.file == nil {
+		return errors.New("缓存已关闭")
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入缓存日志失败: %v", err)
+	}
+	return s.file.Sync()
+}
+
+// compact 用当前有效条目重写日志
+func (s *Spool) compact() error {
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("创建缓存日志失败: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, e := range s.sorted() {
+		data, err := json.Marshal(record{Op: "put", Entry: e})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("写入缓存日志失败: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("替换缓存日志失败: %v", err)
+	}
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("打开缓存日志失败: %v", err)
+	}
+	s.dead = 0
+	return nil
+}
+
+// maybeCompact 失效记录过多时压缩日志
+func (s *Spool) maybeCompact() {
+	if s.dead < 100 || s.dead < len(s.entries) {
+		return
+	}
+	if err := s.compact(); err != nil {
+		logger.Warn("压缩缓存日志失败", logging.KeyError, err)
+	}
+}
+
+// sorted 按写入时间返回全部条目
+func (s *Spool) sorted() []*Entry {
+	list := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// remove 删除条目并记录日志
+func (s *Spool) remove(tag string) error {
+	if _, ok := s.entries[tag]; !ok {
+		return nil
+	}
+	s.unset(tag)
+	s.dead++
+	return s.append(record{Op: "del", Tag: tag})
+}
+
+// prune 按存活时间和容量上限丢弃最旧的条目
+func (s *Spool) prune(now time.Time) {
+	for _, e := range s.sorted() {
+		expired := s.opts.MaxAge > 0 && now.Sub(e.CreatedAt) > s.opts.MaxAge
+		overCount := s.opts.MaxEntries > 0 && len(s.entries) > s.opts.MaxEntries
+		overBytes := s.opts.MaxBytes > 0 && s.bytes > s.opts.MaxBytes
+		if !expired && !overCount && !overBytes {
+			break
+		}
+		logger.Warn("丢弃缓存的任务结果", logging.KeyTag, e.Tag, "created_at", e.CreatedAt)
+		if err := s.remove(e.Tag); err != nil {
+			logger.Warn("删除缓存记录失败", logging.KeyTag, e.Tag, logging.KeyError, err)
+		}
+	}
+}
+
+// Put 写入提交失败的结果，相同 Tag 的旧结果会被覆盖
+func (s *Spool) Put(result *pb.CrawlerResult) error {
+	// 凭证和签名在重放时重新填充，不落盘
+	result = proto.Clone(result).(*pb.CrawlerResult)
+	result.Token = ""
+	result.Timestamp = 0
+	result.Nonce = ""
+	result.Signature = ""
+	data, err := protojson.Marshal(result)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	e := &Entry{
+		Tag:       result.Tag,
+		Result:    data,
+		CreatedAt: now,
+		NextRetry: now.Add(initialBackoff),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, replaced := s.entries[e.Tag]
+	s.set(e)
+	if err := s.append(record{Op: "put", Entry: e}); err != nil {
+		s.unset(e.Tag)
+		if replaced {
+			s.set(previous)
+		}
+		return err
+	}
+	if replaced {
+		s.dead++
+	}
+	s.prune(now)
+	s.maybeCompact()
+	return nil
+}
+
+// Depth 返回缓存中待重放的条目数
+func (s *Spool) Depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Full 缓存是否已达到容量上限，此时写入新结果会丢弃最旧的结果
+func (s *Spool) Full() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return (s.opts.MaxEntries > 0 && len(s.entries) >= s.opts.MaxEntries) ||
+		(s.opts.MaxBytes > 0 && s.bytes >= s.opts.MaxBytes)
+}
+
+// Close 关闭缓存日志
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// due 返回已到重试时间的条目副本
+func (s *Spool) due(now time.Time) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+	var list []Entry
+	for _, e := range s.sorted() {
+		if !e.NextRetry.After(now) {
+			list = append(list, *e)
+		}
+	}
+	return list
+}
+
+// settle 根据重放结果删除条目或推迟下次重试
+func (s *Spool) settle(e Entry, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.entries[e.Tag]
+	if !ok || !current.CreatedAt.Equal(e.CreatedAt) {
+		// 重放期间被新结果覆盖，以新结果为准
+		return
+	}
+	if err == nil || errors.Is(err, ErrPermanent) {
+		if err := s.remove(e.Tag); err != nil {
+			logger.Warn("删除缓存记录失败", logging.KeyTag, e.Tag, logging.KeyError, err)
+		}
+		s.maybeCompact()
+		return
+	}
+	updated := *current
+	updated.Attempts++
+	updated.NextRetry = time.Now().Add(retryBackoff(updated.Attempts))
+	s.dead++
+	s.set(&updated)
+	if err := s.append(record{Op: "put", Entry: &updated}); err != nil {
+		logger.Warn("更新缓存记录失败", logging.KeyTag, e.Tag, logging.KeyError, err)
+	}
+	s.maybeCompact()
+}
+
+// retryBackoff 计算第 attempts 次失败后的重试间隔，带随机抖动
+func retryBackoff(attempts int) time.Duration {
+	backoff := initialBackoff
+	for i := 0; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff + time.Duration(rand.Int63n(int64(backoff/2)))
+}
+
+// Replay 定期重放到期的条目，直到 ctx 结束
+func (s *Spool) Replay(ctx context.Context, interval time.Duration, submit func(context.Context, *pb.CrawlerResult) error) {
+	for {
+		s.replayOnce(ctx, submit)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (s *Spool) replayOnce(ctx context.Context, submit func(context.Context, *pb.CrawlerResult) error) {
+	entries := s.due(time.Now())
+	if len(entries) == 0 {
+		return
+	}
+	replayed := 0
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		entryCtx := logging.WithAttrs(ctx, logging.KeyTag, e.Tag)
+		result := &pb.CrawlerResult{}
+		if err := protojson.Unmarshal(e.Result, result); err != nil {
+			logger.WarnContext(entryCtx, "缓存记录无法解析，丢弃", logging.KeyError, err)
+			s.settle(e, ErrPermanent)
+			continue
+		}
+		err := submit(entryCtx, result)
+		s.settle(e, err)
+		if err != nil {
+			if errors.Is(err, ErrPermanent) {
+				logger.WarnContext(entryCtx, "缓存结果被主控拒绝，丢弃", logging.KeyError, err)
+				continue
+			}
+			// 主控仍不可用时不再继续尝试本轮剩余条目
+			logger.WarnContext(entryCtx, "重放缓存结果失败", logging.KeyError, err)
+			break
+		}
+		replayed++
+	}
+	logger.Info("本轮重放缓存结果", "replayed", replayed, "remaining", s.Depth())
+}
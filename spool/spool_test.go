@@ -0,0 +1,202 @@
+package spool
+
+import (
+	pb "agent/proto"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func put(t *testing.T, s *Spool, tag, data string) {
+	t.Helper()
+	if err := s.Put(&pb.CrawlerResult{Tag: tag, WebData: data}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// makeDue 让条目立即可以重放
+func makeDue(s *Spool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		e.NextRetry = time.Time{}
+	}
+}
+
+func TestLoadSkipsTornLine(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, s, "a", "1")
+	put(t, s, "b", "2")
+	s.Close()
+
+	// 模拟写入过程中断电，最后一行只写了一半
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"put","entry":{"tag":"c","res`)
+	f.Close()
+
+	s, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Depth() != 2 {
+		t.Fatalf("应恢复 2 条记录, got %d", s.Depth())
+	}
+	if _, ok := s.entries["c"]; ok {
+		t.Fatal("写坏的记录不应被恢复")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		put(t, s, "a", fmt.Sprint(i))
+	}
+	put(t, s, "b", "x")
+	s.mu.Lock()
+	s.remove("b")
+	err = s.compact()
+	s.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, logFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != 1 {
+		t.Fatalf("压缩后应只剩 1 条记录, got %d", n)
+	}
+	s, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Depth() != 1 || !bytes.Contains(s.entries["a"].Result, []byte(`"4"`)) {
+		t.Fatalf("压缩后应保留最新的结果: %+v", s.entries)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{MaxEntries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, s, "a", "1")
+	put(t, s, "b", "2")
+	put(t, s, "c", "3")
+	if _, ok := s.entries["a"]; ok || s.Depth() != 2 {
+		t.Fatalf("超过条数上限应丢弃最旧的条目: %v", s.entries)
+	}
+	s.Close()
+
+	s, err = Open(t.TempDir(), Options{MaxBytes: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, s, "a", "small")
+	put(t, s, "b", string(bytes.Repeat([]byte("x"), 60)))
+	if _, ok := s.entries["a"]; ok || s.Depth() != 1 {
+		t.Fatalf("超过容量上限应丢弃最旧的条目: %v", s.entries)
+	}
+	if s.bytes > 100 {
+		t.Fatalf("容量统计不正确: %d", s.bytes)
+	}
+	s.Close()
+
+	s, err = Open(t.TempDir(), Options{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	put(t, s, "old", "1")
+	put(t, s, "new", "2")
+	s.mu.Lock()
+	s.entries["old"].CreatedAt = time.Now().Add(-2 * time.Hour)
+	s.prune(time.Now())
+	s.mu.Unlock()
+	if _, ok := s.entries["old"]; ok || s.Depth() != 1 {
+		t.Fatalf("过期的条目应被丢弃: %v", s.entries)
+	}
+}
+
+func TestSettleOverwritten(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	put(t, s, "a", "old")
+	makeDue(s)
+	entries := s.due(time.Now())
+	if len(entries) != 1 {
+		t.Fatalf("应有 1 条到期记录, got %d", len(entries))
+	}
+
+	// 重放期间同一任务写入了新结果
+	time.Sleep(time.Millisecond)
+	put(t, s, "a", "new")
+	s.settle(entries[0], nil)
+	if s.Depth() != 1 || !bytes.Contains(s.entries["a"].Result, []byte("new")) {
+		t.Fatalf("新结果不应被旧的重放结果删除: %v", s.entries)
+	}
+	s.settle(entries[0], errors.New("unavailable"))
+	if s.entries["a"].Attempts != 0 {
+		t.Fatal("新结果不应累计旧结果的重试次数")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	put(t, s, "ok", "1")
+	put(t, s, "rejected", "2")
+	put(t, s, "retry", "3")
+	makeDue(s)
+
+	var submitted []string
+	s.replayOnce(context.Background(), func(ctx context.Context, r *pb.CrawlerResult) error {
+		submitted = append(submitted, r.Tag)
+		switch r.Tag {
+		case "rejected":
+			return fmt.Errorf("%w: 任务不存在", ErrPermanent)
+		case "retry":
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	if len(submitted) != 3 {
+		t.Fatalf("应依次提交全部到期记录: %v", submitted)
+	}
+	if _, ok := s.entries["rejected"]; ok {
+		t.Fatal("ErrPermanent 的记录应被丢弃")
+	}
+	e, ok := s.entries["retry"]
+	if !ok || s.Depth() != 1 {
+		t.Fatalf("只应保留待重试的记录: %v", s.entries)
+	}
+	if e.Attempts != 1 || !e.NextRetry.After(time.Now()) {
+		t.Fatalf("失败后应推迟下次重试: %+v", e)
+	}
+}
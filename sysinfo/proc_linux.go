@@ -0,0 +1,203 @@
+//go:build linux
+
+package sysinfo
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// procRoot 测试时可替换为其他目录
+var procRoot = "/proc"
+
+// readSample 从 /proc 读取主机状态，单项读取失败时整体返回错误
+func readSample() (*sample, error) {
+	s := &sample{}
+	var err error
+	if s.load, err = readLoadavg(); err != nil {
+		return nil, err
+	}
+	if s.cpuBusy, s.cpuTotal, err = readCPU(); err != nil {
+		return nil, err
+	}
+	if s.memTotal, s.memAvail, err = readMeminfo(); err != nil {
+		return nil, err
+	}
+	if s.rxBytes, s.txBytes, err = readNetDev(); err != nil {
+		return nil, err
+	}
+	if s.uptime, err = readUptime(); err != nil {
+		return nil, err
+	}
+	// 未启用 IPv6 时没有 tcp6
+	for _, name := range []string{"net/tcp", "net/tcp6"} {
+		n, err := countEstablished(name)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		s.tcpConns += n
+	}
+	return s, nil
+}
+
+func readProc(name string) (string, error) {
+	data, err := os.ReadFile(procRoot + "/" + name)
+	return string(data), err
+}
+
+// readLoadavg /proc/loadavg 的前三列
+func readLoadavg() ([3]float64, error) {
+	var load [3]float64
+	data, err := readProc("loadavg")
+	if err != nil {
+		return load, err
+	}
+	fields := strings.Fields(data)
+	if len(fields) < 3 {
+		return load, fmt.Errorf("无法解析 loadavg: %q", data)
+	}
+	for i := range load {
+		if load[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return load, fmt.Errorf("无法解析 loadavg: %q", data)
+		}
+	}
+	return load, nil
+}
+
+// readCPU /proc/stat 中所有 CPU 的累计时间，idle 和 iowait 之外的都计为忙碌
+func readCPU() (busy, total uint64, err error) {
+	data, err := readProc("stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	line, _, _ := strings.Cut(data, "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, fmt.Errorf("无法解析 /proc/stat: %q", line)
+	}
+	// guest 时间已计入 user，不重复累加
+	if len(fields) > 9 {
+		fields = fields[:9]
+	}
+	for i, f := range fields[1:] {
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("无法解析 /proc/stat: %q", line)
+		}
+		total += v
+		if i != 3 && i != 4 {
+			busy += v
+		}
+	}
+	return busy, total, nil
+}
+
+// readMeminfo 总内存和可用内存，单位为字节
+func readMeminfo() (total, avail uint64, err error) {
+	data, err := readProc("meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+	var free, buffers, cached uint64
+	hasAvail := false
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		key, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		v *= 1024
+		switch key {
+		case "MemTotal":
+			total = v
+		case "MemAvailable":
+			avail, hasAvail = v, true
+		case "MemFree":
+			free = v
+		case "Buffers":
+			buffers = v
+		case "Cached":
+			cached = v
+		}
+	}
+	if total == 0 {
+		return 0, 0, fmt.Errorf("无法解析 /proc/meminfo")
+	}
+	// 3.14 之前的内核没有 MemAvailable
+	if !hasAvail {
+		avail = free + buffers + cached
+	}
+	return total, avail, nil
+}
+
+// readNetDev 所有网卡（不含 lo）累计收发的字节数
+func readNetDev() (rx, tx uint64, err error) {
+	data, err := readProc("net/dev")
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(data, "\n") {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 9 {
+			continue
+		}
+		r, err1 := strconv.ParseUint(fields[0], 10, 64)
+		t, err2 := strconv.ParseUint(fields[8], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		rx += r
+		tx += t
+	}
+	return rx, tx, nil
+}
+
+// readUptime 系统启动后经过的秒数
+func readUptime() (float64, error) {
+	data, err := readProc("uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(data)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("无法解析 /proc/uptime: %q", data)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// tcpEstablished /proc/net/tcp 中 ESTABLISHED 状态的编码
+const tcpEstablished = "01"
+
+// countEstablished 已建立的 TCP 连接数
+func countEstablished(name string) (int, error) {
+	data, err := readProc(name)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for i, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 4 {
+			continue
+		}
+		if fields[3] == tcpEstablished {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,68 @@
+//go:build linux
+
+package sysinfo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeProc(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHostFromProc(t *testing.T) {
+	root := t.TempDir()
+	old := procRoot
+	procRoot = root
+	defer func() { procRoot = old }()
+
+	files := map[string]string{
+		"loadavg": "0.50 0.40 0.30 1/200 1234\n",
+		"stat":    "cpu  100 0 100 700 100 0 0 0 0 0\ncpu0 100 0 100 700 100 0 0 0 0 0\n",
+		"meminfo": "MemTotal:        2048 kB\nMemFree:          512 kB\nMemAvailable:    1024 kB\n",
+		"net/dev": "Inter-|   Receive\n face |bytes packets errs drop fifo frame compressed multicast|bytes\n" +
+			"    lo: 999 1 0 0 0 0 0 0 999 1 0 0 0 0 0 0\n  eth0: 1000 10 0 0 0 0 0 0 2000 20 0 0 0 0 0 0\n",
+		"uptime":  "3600.50 7000.00\n",
+		"net/tcp": "  sl  local_address rem_address   st\n   0: 0100007F:1F90 00000000:0000 0A\n   1: 0100007F:1F90 0100007F:D2F0 01\n",
+	}
+	writeProc(t, root, files)
+	c := NewCollector("test")
+	if _, err := c.Host(); err != nil {
+		t.Fatal(err)
+	}
+
+	// CPU 增加 100 忙碌 + 100 空闲，eth0 收发各增加
+	files["stat"] = "cpu  150 0 150 800 100 0 0 0 0 0\n"
+	files["net/dev"] = "Inter-|\n face |\n  eth0: 3000 10 0 0 0 0 0 0 6000 20 0 0 0 0 0 0\n"
+	writeProc(t, root, files)
+	stats, err := c.Host()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Load1 != 0.5 || stats.Load15 != 0.3 {
+		t.Errorf("load 错误: %v %v", stats.Load1, stats.Load15)
+	}
+	if stats.CpuPercent != 50 {
+		t.Errorf("cpu_percent = %v, 期望 50", stats.CpuPercent)
+	}
+	if stats.MemTotalBytes != 2048*1024 || stats.MemAvailableBytes != 1024*1024 {
+		t.Errorf("内存错误: %d %d", stats.MemTotalBytes, stats.MemAvailableBytes)
+	}
+	if stats.TcpConnections != 1 || stats.UptimeSeconds != 3600 {
+		t.Errorf("连接数或运行时间错误: %d %d", stats.TcpConnections, stats.UptimeSeconds)
+	}
+	if stats.NetRxBytesPerSec <= 0 || stats.NetTxBytesPerSec <= stats.NetRxBytesPerSec {
+		t.Errorf("网络速率错误: rx=%v tx=%v", stats.NetRxBytesPerSec, stats.NetTxBytesPerSec)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package sysinfo
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func readSample() (*sample, error) {
+	return nil, fmt.Errorf("不支持在 %s 上采集主机状态", runtime.GOOS)
+}
@@ -0,0 +1,99 @@
+package sysinfo
+
+import (
+	pb "agent/proto"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// sample 一次读取到的主机计数器，速率由相邻两次之差计算
+type sample struct {
+	at       time.Time
+	load     [3]float64
+	cpuBusy  uint64 // 单位为 jiffies
+	cpuTotal uint64
+	memTotal uint64
+	memAvail uint64
+	rxBytes  uint64 // 不含 lo
+	txBytes  uint64
+	tcpConns int
+	uptime   float64
+}
+
+// Collector 采集主机和进程的资源状态，CPU 使用率和网络速率为两次 Collect 之间的平均值
+type Collector struct {
+	version string
+	started time.Time
+
+	mu   sync.Mutex
+	prev *sample
+}
+
+// NewCollector version 为 Agent 的构建版本
+func NewCollector(version string) *Collector {
+	return &Collector{version: version, started: time.Now()}
+}
+
+// Host 主机资源状态，不支持的平台只有 CPU 核数；第一次调用时 CPU 使用率和网络速率为 0
+func (c *Collector) Host() (*pb.HostStats, error) {
+	stats := &pb.HostStats{NumCpu: int32(runtime.NumCPU())}
+	cur, err := readSample()
+	if err != nil {
+		return stats, err
+	}
+	cur.at = time.Now()
+	stats.Load1, stats.Load5, stats.Load15 = cur.load[0], cur.load[1], cur.load[2]
+	stats.MemTotalBytes = cur.memTotal
+	stats.MemAvailableBytes = cur.memAvail
+	stats.TcpConnections = int32(cur.tcpConns)
+	stats.UptimeSeconds = int64(cur.uptime)
+
+	c.mu.Lock()
+	prev := c.prev
+	c.prev = cur
+	c.mu.Unlock()
+	if prev == nil {
+		return stats, nil
+	}
+	if total := delta(cur.cpuTotal, prev.cpuTotal); total > 0 {
+		stats.CpuPercent = float64(delta(cur.cpuBusy, prev.cpuBusy)) / float64(total) * 100
+	}
+	if secs := cur.at.Sub(prev.at).Seconds(); secs > 0 {
+		stats.NetRxBytesPerSec = float64(delta(cur.rxBytes, prev.rxBytes)) / secs
+		stats.NetTxBytesPerSec = float64(delta(cur.txBytes, prev.txBytes)) / secs
+	}
+	return stats, nil
+}
+
+// delta 计数器的增量，计数器回绕或网卡重建时返回 0
+func delta(cur, prev uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
+
+// Runtime Go 运行时和构建信息
+func (c *Collector) Runtime() *pb.RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	stats := &pb.RuntimeStats{
+		GoVersion:      runtime.Version(),
+		BuildVersion:   c.version,
+		Goroutines:     int32(runtime.NumGoroutine()),
+		HeapAllocBytes: m.HeapAlloc,
+		SysBytes:       m.Sys,
+		NumGc:          m.NumGC,
+		UptimeSeconds:  int64(time.Since(c.started).Seconds()),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				stats.VcsRevision = s.Value
+			}
+		}
+	}
+	return stats
+}
@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// otlpTimeout 单次导出的超时
+const otlpTimeout = 10 * time.Second
+
+// NewOTLPExporter endpoint 为采集器的 OTLP/HTTP 地址，如 http://127.0.0.1:4318，span 发送到其 /v1/traces
+func NewOTLPExporter(endpoint string) (sdktrace.SpanExporter, error) {
+	return otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(strings.TrimRight(endpoint, "/")+"/v1/traces"),
+		otlptracehttp.WithTimeout(otlpTimeout),
+	)
+}
+
+// NewWriterExporter 每个 span 以一行 JSON 写入 w，用于本地调试
+func NewWriterExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithWriter(w))
+}
@@ -0,0 +1,237 @@
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"agent/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var logger = logging.For("tracing")
+
+// scopeName 本 Agent 创建的 span 所属的 instrumentation scope
+const scopeName = "agent/tracing"
+
+// propagator 与主控通信时使用 W3C Trace Context 传播链路
+var propagator = propagation.TraceContext{}
+
+// Kind span 类型
+type Kind = trace.SpanKind
+
+const (
+	KindInternal = trace.SpanKindInternal
+	KindClient   = trace.SpanKindClient
+)
+
+// SpanContext 在进程间传播的 span 标识
+type SpanContext = trace.SpanContext
+
+// Span 一段被追踪的操作，未采样或未启用追踪时为 nil，所有方法都可以在 nil 上调用
+type Span struct {
+	span trace.Span
+}
+
+// kvToAttrs 把 key, value, key, value... 转换为属性，多余的 key 被忽略
+func kvToAttrs(kv []any) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			continue
+		}
+		switch v := kv[i+1].(type) {
+		case string:
+			attrs = append(attrs, attribute.String(key, v))
+		case bool:
+			attrs = append(attrs, attribute.Bool(key, v))
+		case int:
+			attrs = append(attrs, attribute.Int(key, v))
+		case int32:
+			attrs = append(attrs, attribute.Int64(key, int64(v)))
+		case int64:
+			attrs = append(attrs, attribute.Int64(key, v))
+		case float64:
+			attrs = append(attrs, attribute.Float64(key, v))
+		case time.Duration:
+			// 耗时统一按毫秒输出
+			attrs = append(attrs, attribute.Float64(key, float64(v)/float64(time.Millisecond)))
+		default:
+			attrs = append(attrs, attribute.String(key, fmt.Sprint(v)))
+		}
+	}
+	return attrs
+}
+
+// SpanContext span 的传播标识
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.span.SpanContext()
+}
+
+// SetAttributes 设置属性，参数为 key, value 交替
+func (s *Span) SetAttributes(kv ...any) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(kvToAttrs(kv)...)
+}
+
+// AddEvent 在当前时间记录事件
+func (s *Span) AddEvent(name string, kv ...any) {
+	s.AddEventAt(name, time.Now(), kv...)
+}
+
+// AddEventAt 在指定时间记录事件
+func (s *Span) AddEventAt(name string, at time.Time, kv ...any) {
+	if s == nil {
+		return
+	}
+	s.span.AddEvent(name, trace.WithTimestamp(at), trace.WithAttributes(kvToAttrs(kv)...))
+}
+
+// RecordError 标记 span 失败，err 为 nil 时忽略
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End 结束 span 并交给导出器，重复调用只生效一次
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.span.End()
+}
+
+// SpanFromContext ctx 中正在记录的 span，没有时返回 nil
+func SpanFromContext(ctx context.Context) *Span {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return nil
+	}
+	return &Span{span: span}
+}
+
+// ContextWithParent 返回以 sc 为父 span 的 ctx，用于把 span 关系带到另一个 ctx
+func ContextWithParent(ctx context.Context, sc SpanContext) context.Context {
+	return trace.ContextWithSpanContext(ctx, sc)
+}
+
+// SpanContextFromContext ctx 中当前 span 的标识，包括未采样的 span
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	return trace.SpanContextFromContext(ctx)
+}
+
+// Inject 把 ctx 中当前 span 的 traceparent 写入 carrier，没有 span 时不写入
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	propagator.Inject(ctx, carrier)
+}
+
+// Start 开始一个 span，父 span 取自 ctx；未启用追踪或未采样时返回的 span 为 nil
+func Start(ctx context.Context, name string, kind Kind, kv ...any) (context.Context, *Span) {
+	return start(ctx, name, kind, kv)
+}
+
+// StartInTrace 在指定链路中开始 span；ctx 中的父 span 属于同一链路时作为父 span，
+// 否则成为该链路在本进程中的根 span。traceID 无效时等同于 Start
+func StartInTrace(ctx context.Context, traceID string, name string, kind Kind, kv ...any) (context.Context, *Span) {
+	id, err := trace.TraceIDFromHex(traceID)
+	if err != nil || id == trace.SpanContextFromContext(ctx).TraceID() {
+		return start(ctx, name, kind, kv)
+	}
+	// 根 span 的链路 ID 由 idGenerator 从 ctx 中取出
+	return start(context.WithValue(ctx, traceIDKey{}, id), name, kind, kv, trace.WithNewRoot())
+}
+
+func start(ctx context.Context, name string, kind Kind, kv []any, opts ...trace.SpanStartOption) (context.Context, *Span) {
+	t := current.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	opts = append(opts, trace.WithSpanKind(kind), trace.WithAttributes(kvToAttrs(kv)...))
+	ctx, span := t.tracer.Start(ctx, name, opts...)
+	if !span.IsRecording() {
+		// 未采样的链路不记录，但仍向主控传播
+		return ctx, nil
+	}
+	return ctx, &Span{span: span}
+}
+
+type traceIDKey struct{}
+
+// idGenerator 随机生成 ID，根 span 优先使用 ctx 中指定的链路 ID
+type idGenerator struct{}
+
+func (idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	if id, ok := ctx.Value(traceIDKey{}).(trace.TraceID); ok {
+		return id, newSpanID()
+	}
+	var id trace.TraceID
+	for !id.IsValid() {
+		crand.Read(id[:])
+	}
+	return id, newSpanID()
+}
+
+func (idGenerator) NewSpanID(context.Context, trace.TraceID) trace.SpanID {
+	return newSpanID()
+}
+
+func newSpanID() trace.SpanID {
+	var s trace.SpanID
+	for !s.IsValid() {
+		crand.Read(s[:])
+	}
+	return s
+}
+
+// Options 追踪参数
+type Options struct {
+	Exporter    sdktrace.SpanExporter
+	SampleRatio float64              // 新链路的采样比例，0~1
+	Resource    []attribute.KeyValue // 附加在所有 span 上的资源属性，如 service.name
+}
+
+type tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+var current atomic.Pointer[tracer]
+
+// Setup 启用追踪，返回的函数用于退出前导出剩余 span 并停止。
+// 新链路按链路 ID 采样，同一链路在所有 Agent 上结果一致；有父 span 时沿用父 span 的采样结果
+func Setup(opts Options) (shutdown func(context.Context) error) {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(opts.Exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(opts.Resource...)),
+		sdktrace.WithIDGenerator(idGenerator{}),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("导出追踪数据失败", logging.KeyError, err)
+	}))
+	t := &tracer{provider: provider, tracer: provider.Tracer(scopeName)}
+	current.Store(t)
+	return func(ctx context.Context) error {
+		current.CompareAndSwap(t, nil)
+		return provider.Shutdown(ctx)
+	}
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// memoryExporter 关闭时保留已导出的 span，便于在 shutdown 之后检查
+type memoryExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (memoryExporter) Shutdown(context.Context) error { return nil }
+
+func TestSpansExportedInTaskTrace(t *testing.T) {
+	if _, span := Start(context.Background(), "disabled", KindInternal); span != nil {
+		t.Fatal("未启用时不应创建 span")
+	}
+	exporter := memoryExporter{tracetest.NewInMemoryExporter()}
+	shutdown := Setup(Options{Exporter: exporter, SampleRatio: 1})
+
+	// 领取任务的 span 属于另一条链路，任务应加入主控下发的链路
+	pollCtx, poll := Start(context.Background(), "GetTask", KindClient)
+	poll.End()
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx, task := StartInTrace(pollCtx, traceID, "HandleTask", KindInternal, "tag", "t1")
+	_, attempt := Start(ctx, "CrawlAttempt", KindClient, "attempt", 1)
+	attempt.AddEvent("ttfb", "duration_ms", 12.5)
+	attempt.RecordError(errors.New("timeout"))
+	attempt.End()
+	task.End()
+	carrier := propagation.MapCarrier{}
+	Inject(ctx, carrier)
+	if got := carrier.Get("traceparent"); !strings.HasPrefix(got, "00-"+traceID+"-") || !strings.HasSuffix(got, "-01") {
+		t.Fatalf("traceparent 应属于任务链路: %s", got)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("应导出 3 个 span, got %d", len(spans))
+	}
+	crawl, handle := spans[1], spans[2]
+	if crawl.SpanContext.TraceID().String() != traceID || handle.SpanContext.TraceID().String() != traceID {
+		t.Fatalf("链路ID不一致: %s %s", crawl.SpanContext.TraceID(), handle.SpanContext.TraceID())
+	}
+	if crawl.Parent.SpanID() != handle.SpanContext.SpanID() || handle.Parent.IsValid() {
+		t.Fatalf("父子关系错误: crawl.parent=%s handle=%s handle.parent=%s", crawl.Parent.SpanID(), handle.SpanContext.SpanID(), handle.Parent.SpanID())
+	}
+	if crawl.Status.Code != codes.Error || len(crawl.Events) != 1 || crawl.Events[0].Name != "ttfb" {
+		t.Fatalf("状态或事件错误: %+v", crawl)
+	}
+}
+
+func TestUnsampledTracePropagated(t *testing.T) {
+	exporter := memoryExporter{tracetest.NewInMemoryExporter()}
+	shutdown := Setup(Options{Exporter: exporter, SampleRatio: 0})
+	ctx, span := Start(context.Background(), "GetTask", KindClient)
+	if span != nil {
+		t.Fatal("未采样时不应记录 span")
+	}
+	carrier := propagation.MapCarrier{}
+	Inject(ctx, carrier)
+	if got := carrier.Get("traceparent"); !strings.HasSuffix(got, "-00") {
+		t.Fatalf("未采样的链路仍应向主控传播: %q", got)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(exporter.GetSpans()); n != 0 {
+		t.Fatalf("未采样的链路不应导出, got %d", n)
+	}
+}